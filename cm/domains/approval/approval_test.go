@@ -0,0 +1,162 @@
+package approval
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const bashPrompt = `
+╭──────────────────────────────────────────────────────────────╮
+│ Bash command                                                 │
+│                                                              │
+│   rm -rf build                                               │
+│   Remove the build directory                                 │
+│                                                              │
+│ Do you want to proceed?                                      │
+│ ❯ 1. Yes                                                     │
+│   2. Yes, and don't ask again for rm commands in             │
+│   /home/dev/project                                          │
+│   3. No, and tell Claude what to do differently (esc)        │
+╰──────────────────────────────────────────────────────────────╯
+`
+
+const editPrompt = `
+╭──────────────────────────────────────────────╮
+│ Edit file                                    │
+│ ╭──────────────────────────────────────────╮ │
+│ │ 12 -  return nil                         │ │
+│ │ 12 +  return err                         │ │
+│ ╰──────────────────────────────────────────╯ │
+│ Do you want to make this edit to main.go?    │
+│   1. Yes                                     │
+│ ❯ 2. Yes, allow all edits during this session│
+│   3. No, and tell Claude what to do (esc)    │
+╰──────────────────────────────────────────────╯
+`
+
+func TestParse(t *testing.T) {
+	prompt, ok := Parse(strings.Split(bashPrompt, "\n"))
+	if !ok {
+		t.Fatal("bash prompt not recognised")
+	}
+	if prompt.Tool != "Bash" || prompt.Subject != "rm -rf build" || prompt.Question != "Do you want to proceed?" {
+		t.Errorf("prompt = %+v", prompt)
+	}
+	if len(prompt.Options) != 3 || prompt.Selected != 1 {
+		t.Fatalf("options = %+v, selected %d", prompt.Options, prompt.Selected)
+	}
+	if want := "Yes, and don't ask again for rm commands in /home/dev/project"; prompt.Options[1].Label != want {
+		t.Errorf("wrapped option = %q, want %q", prompt.Options[1].Label, want)
+	}
+
+	prompt, ok = Parse(strings.Split(editPrompt, "\n"))
+	if !ok {
+		t.Fatal("edit prompt not recognised")
+	}
+	if prompt.Tool != "Edit" || prompt.Subject != "main.go" || prompt.Selected != 2 {
+		t.Errorf("prompt = %+v", prompt)
+	}
+
+	if _, ok := Parse([]string{"> Do you want to proceed?", "", "Sure thing."}); ok {
+		t.Error("question without options should not be a prompt")
+	}
+}
+
+func TestManagerLifecycle(t *testing.T) {
+	m := NewManager()
+	prompt, _ := Parse(strings.Split(editPrompt, "\n"))
+
+	created := m.Sync("s1", "demo", prompt)
+	if created == nil || m.Sync("s1", "demo", prompt) != nil {
+		t.Fatal("a prompt should create exactly one approval while it is on screen")
+	}
+
+	var sent string
+	writer := func(sessionID string, data []byte) error {
+		sent = sessionID + ":" + string(data)
+		return nil
+	}
+
+	tests := []struct {
+		req     ResolveRequest
+		wantErr error
+	}{
+		{ResolveRequest{}, ErrInvalidRequest},
+		{ResolveRequest{Decision: "maybe"}, ErrUnknownDecision},
+		{ResolveRequest{Option: 7}, ErrUnknownOption},
+	}
+	for _, tt := range tests {
+		if _, err := m.Resolve(created.ID, tt.req, writer); err != tt.wantErr {
+			t.Errorf("Resolve(%+v) = %v, want %v", tt.req, err, tt.wantErr)
+		}
+	}
+
+	// Approving moves the highlight from option 2 up to option 1
+	approval, err := m.Resolve(created.ID, ResolveRequest{Decision: DecisionApprove}, writer)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if approval.Status != StatusResolved || approval.Choice != 1 || sent != "s1:\x1b[A\r" {
+		t.Errorf("approval = %s/%d, sent %q", approval.Status, approval.Choice, sent)
+	}
+	if _, err := m.Resolve(created.ID, ResolveRequest{Decision: DecisionDeny}, writer); err != ErrNotPending {
+		t.Errorf("second Resolve = %v, want ErrNotPending", err)
+	}
+	if len(m.List(false)) != 0 || len(m.List(true)) != 1 {
+		t.Error("resolved approval should only be listed with all")
+	}
+
+	// A prompt answered in the terminal is dismissed when it goes away
+	other := m.Sync("s2", "other", prompt)
+	m.Sync("s2", "other", nil)
+	if got, _ := m.Get(other.ID); got.Status != StatusDismissed {
+		t.Errorf("status = %s, want %s", got.Status, StatusDismissed)
+	}
+}
+
+func TestManagerResolveUnlocked(t *testing.T) {
+	m := NewManager()
+	prompt, _ := Parse(strings.Split(editPrompt, "\n"))
+	created := m.Sync("s1", "demo", prompt)
+
+	// The answer is written without holding up the rest of the manager,
+	// and the approval cannot be answered twice meanwhile
+	writer := func(sessionID string, data []byte) error {
+		m.List(true)
+		if _, err := m.Resolve(created.ID, ResolveRequest{Decision: DecisionDeny}, nil); err != ErrNotPending {
+			t.Errorf("Resolve while answering = %v, want ErrNotPending", err)
+		}
+		return nil
+	}
+	if _, err := m.Resolve(created.ID, ResolveRequest{Decision: DecisionApprove}, writer); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	// An answer that could not be sent leaves the approval pending
+	other := m.Sync("s2", "other", prompt)
+	failing := func(string, []byte) error { return errors.New("terminal gone") }
+	if _, err := m.Resolve(other.ID, ResolveRequest{Decision: DecisionApprove}, failing); err == nil {
+		t.Error("Resolve should report the failed write")
+	}
+	if got, _ := m.Get(other.ID); got.Status != StatusPending {
+		t.Errorf("status = %s, want %s", got.Status, StatusPending)
+	}
+}
+
+func TestManagerHistory(t *testing.T) {
+	m := NewManager()
+	prompt, _ := Parse(strings.Split(editPrompt, "\n"))
+	for i := 0; i < maxHistory+10; i++ {
+		m.Sync("s1", "demo", prompt)
+		m.Sync("s1", "demo", nil)
+	}
+	m.Sync("s1", "demo", prompt)
+
+	if got := len(m.List(true)); got != maxHistory+1 {
+		t.Errorf("kept %d approvals, want %d", got, maxHistory+1)
+	}
+	if got := len(m.List(false)); got != 1 {
+		t.Errorf("%d pending approvals, want 1", got)
+	}
+}
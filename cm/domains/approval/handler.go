@@ -0,0 +1,96 @@
+package approval
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Handler handles HTTP requests for approvals
+type Handler struct {
+	approvalManager *Manager
+	writeInput      InputWriter
+}
+
+// NewHandler creates a new approval handler
+func NewHandler(approvalManager *Manager, writeInput InputWriter) *Handler {
+	return &Handler{
+		approvalManager: approvalManager,
+		writeInput:      writeInput,
+	}
+}
+
+// HandleApprovals handles GET /api/approvals. Only pending approvals are
+// listed unless all=true; session filters by session ID.
+func (h *Handler) HandleApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	approvals := h.approvalManager.List(query.Get("all") == "true")
+	if sessionID := query.Get("session"); sessionID != "" {
+		filtered := approvals[:0]
+		for _, approval := range approvals {
+			if approval.SessionID == sessionID {
+				filtered = append(filtered, approval)
+			}
+		}
+		approvals = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
+
+// HandleApproval handles /api/approvals/{id}. GET returns the approval;
+// POST resolves it with {"decision": "approve"|"always"|"deny"} or
+// {"option": n}, typing the answer into the session's terminal.
+func (h *Handler) HandleApproval(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/approvals/"), "/")
+
+	switch r.Method {
+	case "GET":
+		approval, exists := h.approvalManager.Get(id)
+		if !exists {
+			http.Error(w, "Approval not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(approval)
+
+	case "POST":
+		var req ResolveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		approval, err := h.approvalManager.Resolve(id, req, h.writeInput)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, "Approval not found", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrNotPending):
+			http.Error(w, "Approval is no longer pending", http.StatusConflict)
+			return
+		case errors.Is(err, ErrUnknownOption), errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrUnknownDecision):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("Failed to answer approval %s: %v", id, err)
+			http.Error(w, "Failed to send answer to session", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Approval %s for session %s resolved with option %d", id, approval.SessionID, approval.Choice)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(approval)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
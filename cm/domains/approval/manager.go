@@ -0,0 +1,305 @@
+package approval
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Approval states
+const (
+	StatusPending   = "pending"
+	StatusResolved  = "resolved"  // answered through the API
+	StatusDismissed = "dismissed" // answered in the terminal or withdrawn
+)
+
+// Decisions accepted when resolving an approval
+const (
+	DecisionApprove = "approve" // the first "Yes" option
+	DecisionAlways  = "always"  // "Yes, and don't ask again" or similar
+	DecisionDeny    = "deny"    // the "No" option
+)
+
+// Errors returned when resolving approvals
+var (
+	ErrNotFound        = errors.New("approval not found")
+	ErrNotPending      = errors.New("approval is no longer pending")
+	ErrUnknownOption   = errors.New("prompt has no such option")
+	ErrInvalidRequest  = errors.New("either a decision or an option is required")
+	ErrUnknownDecision = errors.New("unknown decision")
+)
+
+// maxHistory bounds the answered and dismissed approvals kept per session
+const maxHistory = 50
+
+// InputWriter sends keystrokes to a session's terminal
+type InputWriter func(sessionID string, data []byte) error
+
+// Keys sent to move through a prompt's options
+const (
+	keyUp    = "\x1b[A"
+	keyDown  = "\x1b[B"
+	keyEnter = "\r"
+)
+
+// Approval is a permission prompt waiting for an answer
+type Approval struct {
+	ID          string    `json:"id"`
+	SessionID   string    `json:"sessionId"`
+	SessionName string    `json:"sessionName"`
+	Status      string    `json:"status"`
+	Choice      int       `json:"choice,omitempty"` // option chosen through the API
+	Created     time.Time `json:"created"`
+	Resolved    time.Time `json:"resolved"`
+	Prompt
+}
+
+// ResolveRequest answers an approval with a decision or an option number
+type ResolveRequest struct {
+	Decision string `json:"decision"`
+	Option   int    `json:"option"`
+}
+
+// Manager tracks the permission prompts shown by each session
+type Manager struct {
+	approvals map[string]*Approval
+	current   map[string]*Approval // latest approval per session
+	answering map[string]*Approval // approvals whose answer is being sent
+	mu        sync.RWMutex
+}
+
+// NewManager creates a new approval manager
+func NewManager() *Manager {
+	return &Manager{
+		approvals: make(map[string]*Approval),
+		current:   make(map[string]*Approval),
+		answering: make(map[string]*Approval),
+	}
+}
+
+// Sync records the prompt currently on a session's screen, or nil if there
+// is none. A new prompt creates a pending approval; a prompt that goes
+// away without being resolved through the API is dismissed. It returns the
+// approval created, if any.
+func (m *Manager) Sync(sessionID, sessionName string, prompt *Prompt) *Approval {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.current[sessionID]
+	if prompt != nil && current != nil && current.fingerprint() == prompt.fingerprint() {
+		// Same prompt redrawn; keep the highlighted option current
+		current.Selected = prompt.Selected
+		return nil
+	}
+
+	if current != nil {
+		if current.Status == StatusPending {
+			current.Status = StatusDismissed
+			current.Resolved = time.Now()
+		}
+		delete(m.current, sessionID)
+	}
+	if prompt == nil {
+		return nil
+	}
+
+	approval := &Approval{
+		ID:          generateApprovalID(),
+		SessionID:   sessionID,
+		SessionName: sessionName,
+		Status:      StatusPending,
+		Created:     time.Now(),
+		Prompt:      *prompt,
+	}
+	m.approvals[approval.ID] = approval
+	m.current[sessionID] = approval
+	m.prune(sessionID)
+	return approval
+}
+
+// prune drops a session's oldest answered and dismissed approvals beyond
+// maxHistory. Must be called with mu held.
+func (m *Manager) prune(sessionID string) {
+	var done []*Approval
+	for _, approval := range m.approvals {
+		if approval.SessionID == sessionID && approval.Status != StatusPending {
+			done = append(done, approval)
+		}
+	}
+	if len(done) <= maxHistory {
+		return
+	}
+	sort.Slice(done, func(i, j int) bool { return done[i].Created.Before(done[j].Created) })
+	for _, approval := range done[:len(done)-maxHistory] {
+		delete(m.approvals, approval.ID)
+	}
+}
+
+// Get returns an approval by ID
+func (m *Manager) Get(id string) (Approval, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	approval, exists := m.approvals[id]
+	if !exists {
+		return Approval{}, false
+	}
+	return *approval, true
+}
+
+// List returns pending approvals, oldest first. With all set, answered
+// and dismissed approvals are included too.
+func (m *Manager) List(all bool) []Approval {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	approvals := make([]Approval, 0, len(m.approvals))
+	for _, approval := range m.approvals {
+		if all || approval.Status == StatusPending {
+			approvals = append(approvals, *approval)
+		}
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].Created.Before(approvals[j].Created)
+	})
+	return approvals
+}
+
+// Resolve answers a pending approval by sending the keystrokes that select
+// the chosen option to its session. The approval is only marked resolved
+// once they are written; until then it is set aside, so that it is not
+// answered twice.
+func (m *Manager) Resolve(id string, req ResolveRequest, send InputWriter) (Approval, error) {
+	m.mu.Lock()
+	if approval, answering := m.answering[id]; answering {
+		m.mu.Unlock()
+		return *approval, ErrNotPending
+	}
+	approval, exists := m.approvals[id]
+	if !exists {
+		m.mu.Unlock()
+		return Approval{}, ErrNotFound
+	}
+	if approval.Status != StatusPending {
+		m.mu.Unlock()
+		return *approval, ErrNotPending
+	}
+	choice, err := approval.choose(req)
+	if err != nil {
+		m.mu.Unlock()
+		return *approval, err
+	}
+	keys := approval.keys(choice)
+	delete(m.approvals, id)
+	m.answering[id] = approval
+	m.mu.Unlock()
+
+	err = send(approval.SessionID, keys)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, answering := m.answering[id]; !answering {
+		// The session was forgotten in the meantime
+		return *approval, err
+	}
+	delete(m.answering, id)
+	m.approvals[id] = approval
+	if err != nil {
+		return *approval, err
+	}
+
+	approval.Status = StatusResolved
+	approval.Choice = choice
+	approval.Resolved = time.Now()
+	return *approval, nil
+}
+
+// Forget drops all approvals for a session
+func (m *Manager) Forget(sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, approval := range m.approvals {
+		if approval.SessionID == sessionID {
+			delete(m.approvals, id)
+		}
+	}
+	for id, approval := range m.answering {
+		if approval.SessionID == sessionID {
+			delete(m.answering, id)
+		}
+	}
+	delete(m.current, sessionID)
+}
+
+// choose returns the option number a request selects
+func (p *Prompt) choose(req ResolveRequest) (int, error) {
+	if req.Option != 0 {
+		for _, option := range p.Options {
+			if option.Number == req.Option {
+				return option.Number, nil
+			}
+		}
+		return 0, ErrUnknownOption
+	}
+
+	var match func(label string) bool
+	switch req.Decision {
+	case DecisionApprove:
+		match = func(label string) bool { return label == "yes" || strings.HasPrefix(label, "yes ") }
+	case DecisionAlways:
+		match = func(label string) bool { return strings.HasPrefix(label, "yes,") }
+	case DecisionDeny:
+		match = func(label string) bool { return strings.HasPrefix(label, "no") }
+	case "":
+		return 0, ErrInvalidRequest
+	default:
+		return 0, ErrUnknownDecision
+	}
+
+	for _, option := range p.Options {
+		if match(strings.ToLower(option.Label)) {
+			return option.Number, nil
+		}
+	}
+	return 0, ErrUnknownOption
+}
+
+// keys returns the keystrokes that move the highlight from the selected
+// option to choice and confirm it
+func (p *Prompt) keys(choice int) []byte {
+	from, to := -1, -1
+	for i, option := range p.Options {
+		if option.Number == p.Selected {
+			from = i
+		}
+		if option.Number == choice {
+			to = i
+		}
+	}
+	if from < 0 {
+		from = 0
+	}
+
+	var keys strings.Builder
+	for ; from < to; from++ {
+		keys.WriteString(keyDown)
+	}
+	for ; from > to; from-- {
+		keys.WriteString(keyUp)
+	}
+	keys.WriteString(keyEnter)
+	return []byte(keys.String())
+}
+
+// generateApprovalID generates a unique approval ID
+func generateApprovalID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("approval-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,153 @@
+package approval
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Prompt is a tool permission prompt read off a Claude Code screen
+type Prompt struct {
+	Tool     string   `json:"tool"`              // Bash, Edit, Write, WebFetch, ...
+	Header   string   `json:"header"`            // the prompt's title, such as "Bash command"
+	Subject  string   `json:"subject,omitempty"` // the command, file or URL in question
+	Details  []string `json:"details,omitempty"` // the rest of the prompt body
+	Question string   `json:"question"`
+	Options  []Option `json:"options"`
+	Selected int      `json:"selected"` // number of the highlighted option
+}
+
+// Option is one of the numbered answers offered by a prompt
+type Option struct {
+	Number int    `json:"number"`
+	Label  string `json:"label"`
+}
+
+// toolHeaders maps prompt titles to Claude Code tool names
+var toolHeaders = map[string]string{
+	"bash command": "Bash",
+	"edit file":    "Edit",
+	"create file":  "Write",
+	"write file":   "Write",
+	"read file":    "Read",
+	"fetch":        "WebFetch",
+	"web search":   "WebSearch",
+	"notebook":     "NotebookEdit",
+}
+
+var (
+	questionPattern = regexp.MustCompile(`^Do you want to .*\?$`)
+	optionPattern   = regexp.MustCompile(`^(❯|>)?\s*(\d+)\.\s+(.+)$`)
+	filePattern     = regexp.MustCompile(`(?:edit to|create|write to|overwrite) (\S+)\?$`)
+	boxBorders      = "│╭╮╰╯─┌┐└┘"
+)
+
+// Parse looks for a permission prompt on a screen, given one string per
+// row. Prompts are recognised by their "Do you want to ...?" question
+// followed by numbered options.
+func Parse(lines []string) (*Prompt, bool) {
+	cleaned := make([]string, len(lines))
+	for i, line := range lines {
+		cleaned[i] = strings.TrimSpace(strings.Trim(strings.TrimSpace(line), boxBorders))
+	}
+
+	// The question nearest the bottom is the live one
+	question := -1
+	for i := len(cleaned) - 1; i >= 0; i-- {
+		if questionPattern.MatchString(cleaned[i]) {
+			question = i
+			break
+		}
+	}
+	if question < 0 {
+		return nil, false
+	}
+
+	prompt := &Prompt{Question: cleaned[question]}
+	for _, line := range cleaned[question+1:] {
+		match := optionPattern.FindStringSubmatch(line)
+		if match == nil {
+			if len(prompt.Options) == 0 {
+				continue
+			}
+			if line == "" {
+				break
+			}
+			// Long labels wrap onto the following rows
+			last := &prompt.Options[len(prompt.Options)-1]
+			last.Label += " " + line
+			continue
+		}
+		number, _ := strconv.Atoi(match[2])
+		prompt.Options = append(prompt.Options, Option{Number: number, Label: strings.TrimSpace(match[3])})
+		if match[1] != "" {
+			prompt.Selected = number
+		}
+	}
+	if len(prompt.Options) == 0 {
+		return nil, false
+	}
+	if prompt.Selected == 0 {
+		prompt.Selected = prompt.Options[0].Number
+	}
+
+	// The body runs from the top of the prompt's box down to the question
+	top := 0
+	for i := question - 1; i >= 0; i-- {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "╭") {
+			top = i + 1
+			break
+		}
+	}
+	var body []string
+	for _, line := range cleaned[top:question] {
+		if line != "" {
+			body = append(body, line)
+		}
+	}
+
+	if len(body) > 0 {
+		prompt.Header = body[0]
+		body = body[1:]
+	}
+	prompt.Tool = toolName(prompt.Header)
+	if match := filePattern.FindStringSubmatch(prompt.Question); match != nil {
+		prompt.Subject = match[1]
+	} else if len(body) > 0 {
+		prompt.Subject = body[0]
+		body = body[1:]
+	}
+	prompt.Details = body
+	return prompt, true
+}
+
+// toolName maps a prompt header to the tool it asks about
+func toolName(header string) string {
+	lower := strings.ToLower(header)
+	for prefix, tool := range toolHeaders {
+		if strings.HasPrefix(lower, prefix) {
+			return tool
+		}
+	}
+	// MCP tools are titled with their server and tool name
+	if strings.Contains(lower, "(mcp)") || strings.HasPrefix(lower, "mcp__") {
+		return strings.Fields(header)[0]
+	}
+	return header
+}
+
+// fingerprint identifies a prompt so that redraws of the same prompt are
+// not reported twice
+func (p *Prompt) fingerprint() string {
+	var b strings.Builder
+	b.WriteString(p.Header)
+	b.WriteByte(0)
+	b.WriteString(p.Subject)
+	b.WriteByte(0)
+	b.WriteString(p.Question)
+	for _, option := range p.Options {
+		b.WriteByte(0)
+		b.WriteString(option.Label)
+	}
+	return b.String()
+}
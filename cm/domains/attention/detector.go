@@ -0,0 +1,177 @@
+package attention
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// State describes what a session is doing
+type State string
+
+// Session activity states
+const (
+	Working       State = "working"        // producing output or showing a busy indicator
+	Idle          State = "idle"           // finished, waiting at its input prompt
+	AwaitingInput State = "awaiting_input" // blocked on a question or permission prompt
+)
+
+// Default detector timings
+const (
+	DefaultQuietPeriod = 1500 * time.Millisecond
+	DefaultSettleTime  = 300 * time.Millisecond
+)
+
+// promptLines is how many lines above the cursor, and how many of the last
+// lines on screen, are searched for prompts
+const promptLines = 12
+
+// PromptPatterns match questions that block until the user answers, such
+// as Claude Code's tool permission and trust prompts
+var PromptPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)do you want to (proceed|make this edit|create|allow|run|overwrite)`),
+	regexp.MustCompile(`(?i)do you trust the files in this folder`),
+	regexp.MustCompile(`❯\s*1\.\s*Yes`),
+	regexp.MustCompile(`(?i)yes, and don't ask again`),
+	regexp.MustCompile(`(?i)\(y/n\)|\[y/n\]`),
+	regexp.MustCompile(`(?i)press enter to continue`),
+}
+
+// BusyPatterns match indicators shown while Claude is working, which keep
+// a session working even when its output briefly pauses
+var BusyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)esc to interrupt`),
+}
+
+// questionLine matches a cursor line that ends in a question, such as a
+// shell read prompt
+var questionLine = regexp.MustCompile(`[?:]\s*$`)
+
+// View is what the detector sees of the terminal
+type View struct {
+	Lines     []string // visible screen text, one entry per row
+	CursorRow int
+}
+
+// Detector tracks a session's activity state from its output timing and
+// screen contents. It is not safe for concurrent use.
+type Detector struct {
+	QuietPeriod time.Duration // silence after which output is considered finished
+	SettleTime  time.Duration // silence before a prompt on screen is trusted
+
+	PromptPatterns []*regexp.Regexp // defaults to the package's PromptPatterns
+	BusyPatterns   []*regexp.Regexp // defaults to the package's BusyPatterns
+
+	state      State
+	since      time.Time
+	lastOutput time.Time
+	lastInput  time.Time
+}
+
+// NewDetector creates a detector in the working state, as a session is
+// while its program starts up
+func NewDetector(now time.Time) *Detector {
+	return &Detector{
+		QuietPeriod:    DefaultQuietPeriod,
+		SettleTime:     DefaultSettleTime,
+		PromptPatterns: PromptPatterns,
+		BusyPatterns:   BusyPatterns,
+		state:          Working,
+		since:          now,
+		lastOutput:     now,
+	}
+}
+
+// State returns the current state and when it was entered
+func (d *Detector) State() (State, time.Time) {
+	return d.state, d.since
+}
+
+// LastOutput returns when output was last seen
+func (d *Detector) LastOutput() time.Time {
+	return d.lastOutput
+}
+
+// Output records that the session produced output
+func (d *Detector) Output(now time.Time) {
+	d.lastOutput = now
+}
+
+// Input records that the user typed into the session. An answered prompt
+// counts as work resuming until the screen says otherwise.
+func (d *Detector) Input(now time.Time) {
+	d.lastInput = now
+	if d.state == AwaitingInput {
+		d.set(Working, now)
+	}
+}
+
+// Evaluate updates the state from the current screen and reports whether
+// it changed
+func (d *Detector) Evaluate(view View, now time.Time) bool {
+	return d.set(d.classify(view, now), now)
+}
+
+// classify decides the state for the current screen
+func (d *Detector) classify(view View, now time.Time) State {
+	quiet := now.Sub(d.lastOutput)
+	region := promptRegion(view)
+
+	if matchAny(d.BusyPatterns, region) {
+		return Working
+	}
+	if quiet < d.SettleTime {
+		return Working
+	}
+	if matchAny(d.PromptPatterns, region) {
+		return AwaitingInput
+	}
+	if quiet < d.QuietPeriod {
+		return Working
+	}
+
+	// A question on the cursor line that arrived after the last keystroke
+	if view.CursorRow >= 0 && view.CursorRow < len(view.Lines) && d.lastOutput.After(d.lastInput) {
+		if questionLine.MatchString(view.Lines[view.CursorRow]) {
+			return AwaitingInput
+		}
+	}
+	return Idle
+}
+
+// set changes the state, reporting whether it differed
+func (d *Detector) set(state State, now time.Time) bool {
+	if state == d.state {
+		return false
+	}
+	d.state = state
+	d.since = now
+	return true
+}
+
+// promptRegion returns the text near the cursor and at the bottom of the
+// screen, where prompts are drawn
+func promptRegion(view View) string {
+	lines := view.Lines
+	end := len(lines)
+	for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	start := end - promptLines
+	if cursorStart := view.CursorRow - promptLines; cursorStart < start {
+		start = cursorStart
+	}
+	if start < 0 {
+		start = 0
+	}
+	return strings.Join(lines[start:end], "\n")
+}
+
+func matchAny(patterns []*regexp.Regexp, text string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package attention
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func view(screen string) View {
+	lines := strings.Split(screen, "\n")
+	return View{Lines: lines, CursorRow: len(lines) - 1}
+}
+
+func TestDetectorStates(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	tests := []struct {
+		name   string
+		screen string
+		quiet  time.Duration
+		want   State
+	}{
+		{name: "streaming output", screen: "Reading files", quiet: 100 * time.Millisecond, want: Working},
+		{name: "busy indicator while quiet", screen: "✻ Thinking… (esc to interrupt)\n> ", quiet: 5 * time.Second, want: Working},
+		{name: "permission prompt", screen: "Bash command\n  rm -rf build\nDo you want to proceed?\n❯ 1. Yes\n  2. No", quiet: 500 * time.Millisecond, want: AwaitingInput},
+		{name: "prompt still rendering", screen: "Do you want to proceed?", quiet: 100 * time.Millisecond, want: Working},
+		{name: "shell question", screen: "Overwrite config? ", quiet: 2 * time.Second, want: AwaitingInput},
+		{name: "pause between chunks", screen: "$ make", quiet: time.Second, want: Working},
+		{name: "finished at prompt", screen: "Done.\n> ", quiet: 2 * time.Second, want: Idle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector(start)
+			d.Output(start)
+			d.Evaluate(view(tt.screen), start.Add(tt.quiet))
+			if state, _ := d.State(); state != tt.want {
+				t.Errorf("state = %s, want %s", state, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectorTransitions(t *testing.T) {
+	start := time.Unix(1000, 0)
+	d := NewDetector(start)
+	prompt := view("Do you want to make this edit to main.go?\n❯ 1. Yes")
+
+	if d.Evaluate(prompt, start.Add(100*time.Millisecond)) {
+		t.Fatal("state changed before the prompt settled")
+	}
+	asked := start.Add(time.Second)
+	if !d.Evaluate(prompt, asked) {
+		t.Fatal("prompt should move the session to awaiting_input")
+	}
+	if state, since := d.State(); state != AwaitingInput || !since.Equal(asked) {
+		t.Errorf("state = %s since %v, want %s since %v", state, since, AwaitingInput, asked)
+	}
+
+	// Answering resumes work immediately
+	answered := asked.Add(time.Second)
+	d.Input(answered)
+	d.Output(answered)
+	if state, since := d.State(); state != Working || !since.Equal(answered) {
+		t.Errorf("state after input = %s since %v, want %s", state, since, Working)
+	}
+
+	// Once the output goes quiet without a prompt the session is idle
+	d.Evaluate(view("Edited main.go\n> "), answered.Add(5*time.Second))
+	if state, _ := d.State(); state != Idle {
+		t.Errorf("state = %s, want %s", state, Idle)
+	}
+}
+
+func TestDetectorCustomPatterns(t *testing.T) {
+	start := time.Unix(1000, 0)
+	d := NewDetector(start)
+	d.PromptPatterns = []*regexp.Regexp{regexp.MustCompile(`Apply this change\?`)}
+	d.BusyPatterns = []*regexp.Regexp{regexp.MustCompile(`Thinking\.\.\.`)}
+
+	d.Evaluate(view("Thinking..."), start.Add(5*time.Second))
+	if state, _ := d.State(); state != Working {
+		t.Errorf("busy pattern: state = %s, want %s", state, Working)
+	}
+	d.Evaluate(view("Apply this change? (yes/no)"), start.Add(5*time.Second))
+	if state, _ := d.State(); state != AwaitingInput {
+		t.Errorf("prompt pattern: state = %s, want %s", state, AwaitingInput)
+	}
+	d.Evaluate(view("Do you want to proceed?\n> "), start.Add(5*time.Second))
+	if state, _ := d.State(); state != Idle {
+		t.Errorf("default patterns replaced: state = %s, want %s", state, Idle)
+	}
+}
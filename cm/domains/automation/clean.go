@@ -0,0 +1,65 @@
+package automation
+
+// cleanOutput strips escape sequences and carriage returns from terminal
+// output so that patterns can be matched against the text. For each byte
+// of the result, ends holds the offset in data just past the byte it came
+// from.
+func cleanOutput(data []byte) (string, []int) {
+	text := make([]byte, 0, len(data))
+	ends := make([]int, 0, len(data))
+
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b == 0x1b:
+			i = skipEscape(data, i) - 1
+		case b == '\r' || b == 0x07 || b == 0x7f:
+		case b == '\b':
+			if len(text) > 0 {
+				text = text[:len(text)-1]
+				ends = ends[:len(ends)-1]
+			}
+		default:
+			text = append(text, b)
+			ends = append(ends, i+1)
+		}
+	}
+	return string(text), ends
+}
+
+// skipEscape returns the offset just past the escape sequence starting at
+// data[i]. Incomplete sequences run to the end of data.
+func skipEscape(data []byte, i int) int {
+	i++
+	if i >= len(data) {
+		return i
+	}
+
+	switch data[i] {
+	case '[': // CSI: parameters and intermediates, then a final byte
+		for i++; i < len(data); i++ {
+			if data[i] >= 0x40 && data[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return i
+	case ']', 'P', '_', '^', 'X': // strings ended by BEL or ST
+		for i++; i < len(data); i++ {
+			if data[i] == 0x07 {
+				return i + 1
+			}
+			if data[i] == 0x1b && i+1 < len(data) && data[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return i
+	}
+
+	// Two byte sequences, possibly with intermediates such as ESC ( B
+	for ; i < len(data); i++ {
+		if data[i] >= 0x30 {
+			return i + 1
+		}
+	}
+	return i
+}
@@ -0,0 +1,233 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/user/claude-manager/domains/terminal"
+)
+
+// Where a step's pattern is matched
+const (
+	MatchOutput = "output" // output since the previous match, escape sequences removed
+	MatchScreen = "screen" // the visible screen
+)
+
+// Default and maximum timings
+const (
+	DefaultStepTimeout   = 10 * time.Second
+	DefaultScriptTimeout = 2 * time.Minute
+	MaxScriptTimeout     = 10 * time.Minute
+)
+
+// maxCapture bounds the output kept in a step's result
+const maxCapture = 16 * 1024
+
+// ErrSessionClosed is reported when the session ends during a step
+var ErrSessionClosed = errors.New("session closed")
+
+// Terminal is the part of a session a script drives. It is implemented by
+// terminal.PTYSession.
+type Terminal interface {
+	OutputNotify() <-chan struct{}
+	OutputOffset() int64
+	OutputSince(offset int64) ([]byte, int64)
+	ScreenText() string
+	WriteInput(data []byte) error
+	Done() <-chan struct{}
+}
+
+// Script is a sequence of steps, each optionally waiting for a pattern
+// and then sending input
+type Script struct {
+	Steps   []Step `json:"steps"`
+	Timeout int    `json:"timeout"` // milliseconds for the whole script
+}
+
+// Step waits until Expect matches, then sends Send followed by Keys
+type Step struct {
+	Expect   string   `json:"expect"`   // regular expression; empty sends immediately
+	Match    string   `json:"match"`    // "output" (default) or "screen"
+	Timeout  int      `json:"timeout"`  // milliseconds to wait for Expect
+	Send     string   `json:"send"`     // text to type
+	Keys     []string `json:"keys"`     // named keys to press after Send
+	Optional bool     `json:"optional"` // carry on without sending if Expect times out
+
+	pattern *regexp.Regexp
+	input   []byte
+}
+
+// StepResult reports how a step went
+type StepResult struct {
+	Step    int      `json:"step"`
+	Matched bool     `json:"matched"`
+	Match   string   `json:"match,omitempty"`  // text that matched Expect
+	Groups  []string `json:"groups,omitempty"` // submatches of Expect
+	Output  string   `json:"output"`           // output consumed while waiting
+	Sent    int      `json:"sent"`             // bytes of input written
+	Elapsed float64  `json:"elapsed"`          // seconds
+	Error   string   `json:"error,omitempty"`
+}
+
+// Result reports how a script went
+type Result struct {
+	Success bool         `json:"success"`
+	Steps   []StepResult `json:"steps"`
+	Elapsed float64      `json:"elapsed"` // seconds
+}
+
+// Compile validates the script's patterns, match modes and keys
+func (s *Script) Compile() error {
+	if len(s.Steps) == 0 {
+		return errors.New("script has no steps")
+	}
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.Expect != "" {
+			pattern, err := regexp.Compile(step.Expect)
+			if err != nil {
+				return fmt.Errorf("step %d: invalid pattern: %v", i+1, err)
+			}
+			step.pattern = pattern
+		}
+		switch step.Match {
+		case "":
+			step.Match = MatchOutput
+		case MatchOutput, MatchScreen:
+		default:
+			return fmt.Errorf("step %d: unknown match %q", i+1, step.Match)
+		}
+
+		step.input = []byte(step.Send)
+		for _, key := range step.Keys {
+			seq, err := terminal.KeySequence(key)
+			if err != nil {
+				return fmt.Errorf("step %d: %v", i+1, err)
+			}
+			step.input = append(step.input, seq...)
+		}
+		if step.pattern == nil && len(step.input) == 0 {
+			return fmt.Errorf("step %d: nothing to expect or send", i+1)
+		}
+	}
+	return nil
+}
+
+// Run executes a compiled script against a terminal. Matching starts with
+// the output produced after Run is called.
+func (s *Script) Run(ctx context.Context, term Terminal) Result {
+	return s.RunFrom(ctx, term, term.OutputOffset())
+}
+
+// RunFrom executes a compiled script, matching from the given output
+// offset onwards
+func (s *Script) RunFrom(ctx context.Context, term Terminal, offset int64) Result {
+	timeout := DefaultScriptTimeout
+	if s.Timeout > 0 {
+		timeout = min(time.Duration(s.Timeout)*time.Millisecond, MaxScriptTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	result := Result{Success: true, Steps: make([]StepResult, 0, len(s.Steps))}
+	cursor := offset
+
+	for i, step := range s.Steps {
+		stepStarted := time.Now()
+		stepResult := StepResult{Step: i + 1}
+
+		var err error
+		if step.pattern != nil {
+			cursor, err = step.expect(ctx, term, cursor, &stepResult)
+		}
+		if err == nil && len(step.input) > 0 {
+			err = term.WriteInput(step.input)
+			if err == nil {
+				stepResult.Sent = len(step.input)
+			}
+		}
+
+		stepResult.Elapsed = time.Since(stepStarted).Seconds()
+		if err != nil {
+			stepResult.Error = err.Error()
+		}
+		result.Steps = append(result.Steps, stepResult)
+
+		if err != nil && !(step.Optional && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil) {
+			result.Success = false
+			break
+		}
+	}
+
+	result.Elapsed = time.Since(started).Seconds()
+	return result
+}
+
+// expect waits until the step's pattern matches, returning the output
+// offset the next step matches from
+func (step *Step) expect(ctx context.Context, term Terminal, cursor int64, result *StepResult) (int64, error) {
+	timeout := DefaultStepTimeout
+	if step.Timeout > 0 {
+		timeout = time.Duration(step.Timeout) * time.Millisecond
+	}
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		// Register for new output before looking, so none is missed
+		notify := term.OutputNotify()
+
+		output, from := term.OutputSince(cursor)
+		text, ends := cleanOutput(output)
+		result.Output = tail(text)
+
+		if step.Match == MatchScreen {
+			if match := step.pattern.FindStringSubmatch(term.ScreenText()); match != nil {
+				result.Matched = true
+				result.Match, result.Groups = match[0], match[1:]
+				return from + int64(len(output)), nil
+			}
+		} else if loc := step.pattern.FindStringSubmatchIndex(text); loc != nil {
+			result.Matched = true
+			result.Match = text[loc[0]:loc[1]]
+			for g := 2; g < len(loc); g += 2 {
+				if loc[g] >= 0 {
+					result.Groups = append(result.Groups, text[loc[g]:loc[g+1]])
+				} else {
+					result.Groups = append(result.Groups, "")
+				}
+			}
+			result.Output = tail(text[:loc[1]])
+
+			// Later steps match after the end of this match
+			consumed := 0
+			if loc[1] > 0 {
+				consumed = ends[loc[1]-1]
+			}
+			return from + int64(consumed), nil
+		}
+
+		select {
+		case <-notify:
+		case <-term.Done():
+			return cursor, ErrSessionClosed
+		case <-stepCtx.Done():
+			if ctx.Err() != nil {
+				return cursor, ctx.Err()
+			}
+			return cursor, fmt.Errorf("timed out waiting for %q: %w", step.Expect, context.DeadlineExceeded)
+		}
+	}
+}
+
+// tail keeps the end of long captured output
+func tail(text string) string {
+	if len(text) > maxCapture {
+		return text[len(text)-maxCapture:]
+	}
+	return text
+}
@@ -0,0 +1,165 @@
+package automation
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTerminal echoes a canned reply to each line of input it receives
+type fakeTerminal struct {
+	mu      sync.Mutex
+	output  []byte
+	notify  chan struct{}
+	replies map[string]string
+	written []string
+	done    chan struct{}
+}
+
+func newFakeTerminal(replies map[string]string) *fakeTerminal {
+	return &fakeTerminal{replies: replies, done: make(chan struct{})}
+}
+
+func (f *fakeTerminal) emit(data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.output = append(f.output, data...)
+	if f.notify != nil {
+		close(f.notify)
+		f.notify = nil
+	}
+}
+
+func (f *fakeTerminal) OutputNotify() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.notify == nil {
+		f.notify = make(chan struct{})
+	}
+	return f.notify
+}
+
+func (f *fakeTerminal) OutputOffset() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int64(len(f.output))
+}
+
+func (f *fakeTerminal) OutputSince(offset int64) ([]byte, int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]byte(nil), f.output[offset:]...), offset
+}
+
+func (f *fakeTerminal) ScreenText() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lines := strings.Split(string(f.output), "\n")
+	return lines[len(lines)-1]
+}
+
+func (f *fakeTerminal) WriteInput(data []byte) error {
+	f.mu.Lock()
+	f.written = append(f.written, string(data))
+	reply := f.replies[string(data)]
+	f.mu.Unlock()
+	if reply != "" {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			f.emit(reply)
+		}()
+	}
+	return nil
+}
+
+func (f *fakeTerminal) Done() <-chan struct{} {
+	return f.done
+}
+
+func TestCleanOutput(t *testing.T) {
+	raw := "\x1b[?25l\x1b[2K\x1b[1G\x1b[32m❯\x1b[0m trust\x1b]0;title\x07 this\r\nab\bc"
+	text, ends := cleanOutput([]byte(raw))
+	if text != "❯ trust this\nac" {
+		t.Errorf("cleanOutput = %q", text)
+	}
+	if len(ends) != len(text) || ends[len(ends)-1] != len(raw) {
+		t.Errorf("ends = %v, want %d entries ending at %d", ends, len(text), len(raw))
+	}
+}
+
+func TestScriptRun(t *testing.T) {
+	term := newFakeTerminal(map[string]string{
+		"\r":        "Welcome!\r\n> ",
+		"/init\r":   "Analyzing codebase...\r\nCreated CLAUDE.md\r\n> ",
+		"status\r":  "ok 42\r\n> ",
+		"missing\r": "",
+	})
+
+	script := Script{Steps: []Step{
+		{Expect: `trust the files`, Keys: []string{"Enter"}},
+		{Expect: `> $`, Send: "/init", Keys: []string{"Enter"}},
+		{Expect: `Created (\S+)`},
+		{Expect: `> `, Match: MatchScreen, Send: "status\r"},
+		{Expect: `ok (\d+)`},
+		{Expect: `never`, Timeout: 50, Optional: true},
+		{Expect: `> `, Match: MatchScreen},
+	}}
+	if err := script.Compile(); err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		term.emit("\x1b[1mDo you \x1b[0mtrust the files in this folder?\r\n")
+	}()
+	result := script.Run(context.Background(), term)
+
+	if !result.Success || len(result.Steps) != 7 {
+		t.Fatalf("result = %+v", result)
+	}
+	if got := result.Steps[2].Groups; len(got) != 1 || got[0] != "CLAUDE.md" {
+		t.Errorf("step 3 groups = %q", got)
+	}
+	if got := result.Steps[4].Groups; len(got) != 1 || got[0] != "42" {
+		t.Errorf("step 5 groups = %q", got)
+	}
+	if result.Steps[5].Matched || result.Steps[5].Error == "" {
+		t.Errorf("optional step should time out: %+v", result.Steps[5])
+	}
+	if strings.Join(term.written, "|") != "\r|/init\r|status\r" {
+		t.Errorf("written = %q", term.written)
+	}
+}
+
+func TestScriptFailures(t *testing.T) {
+	for _, script := range []Script{
+		{},
+		{Steps: []Step{{Expect: "("}}},
+		{Steps: []Step{{Expect: "x", Match: "pixels"}}},
+		{Steps: []Step{{Send: "x", Keys: []string{"Hyper"}}}},
+		{Steps: []Step{{}}},
+	} {
+		if err := script.Compile(); err == nil {
+			t.Errorf("Compile(%+v) should fail", script)
+		}
+	}
+
+	// A required step that times out stops the script
+	term := newFakeTerminal(nil)
+	script := Script{Steps: []Step{{Expect: "never", Timeout: 20}, {Send: "after"}}}
+	script.Compile()
+	result := script.Run(context.Background(), term)
+	if result.Success || len(result.Steps) != 1 || len(term.written) != 0 {
+		t.Errorf("result = %+v, written %q", result, term.written)
+	}
+
+	// So does the session ending
+	close(term.done)
+	script = Script{Steps: []Step{{Expect: "never"}}}
+	script.Compile()
+	if result := script.Run(context.Background(), term); result.Steps[0].Error != ErrSessionClosed.Error() {
+		t.Errorf("error = %q, want %q", result.Steps[0].Error, ErrSessionClosed)
+	}
+}
@@ -0,0 +1,114 @@
+package environment
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+// validName matches the variable names sessions may be given
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidName reports whether name can be used as a variable name
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// ValidPattern reports whether a denylist entry is a variable name,
+// optionally ending in * to match every name with that prefix
+func ValidPattern(pattern string) bool {
+	return pattern == "*" || ValidName(strings.TrimSuffix(pattern, "*"))
+}
+
+// Strip removes the variables whose names match any of the patterns
+func Strip(env []string, deny []string) []string {
+	if len(deny) == 0 {
+		return env
+	}
+	kept := make([]string, 0, len(env))
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if !denied(name, deny) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+func denied(name string, deny []string) bool {
+	for _, pattern := range deny {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge combines NAME=value lists, later lists overriding earlier ones. A
+// variable keeps the position where it first appeared.
+func Merge(layers ...[]string) []string {
+	var merged []string
+	index := make(map[string]int)
+	for _, layer := range layers {
+		for _, entry := range layer {
+			name, _, _ := strings.Cut(entry, "=")
+			if i, ok := index[name]; ok {
+				merged[i] = entry
+				continue
+			}
+			index[name] = len(merged)
+			merged = append(merged, entry)
+		}
+	}
+	return merged
+}
+
+// Build returns the environment for a session: the server's less the
+// denied variables, then the agent's, then the env files', then the
+// explicit variables. Relative env files are read from dir. It also returns
+// the options with the file paths made absolute.
+func Build(server, agent []string, opts session.EnvOptions, dir string) ([]string, session.EnvOptions, error) {
+	for _, pattern := range opts.EnvDeny {
+		if !ValidPattern(pattern) {
+			return nil, opts, fmt.Errorf("invalid envDeny entry %q", pattern)
+		}
+	}
+
+	overrides := make([]string, 0, len(opts.Env))
+	for _, v := range opts.Env {
+		if !ValidName(v.Name) {
+			return nil, opts, fmt.Errorf("invalid variable name %q", v.Name)
+		}
+		if strings.ContainsRune(v.Value, 0) {
+			return nil, opts, fmt.Errorf("variable %s contains a NUL byte", v.Name)
+		}
+		overrides = append(overrides, v.Name+"="+v.Value)
+	}
+
+	resolved := opts
+	resolved.EnvFiles = make([]string, 0, len(opts.EnvFiles))
+	layers := [][]string{Strip(server, opts.EnvDeny), agent}
+	for _, file := range opts.EnvFiles {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		vars, err := LoadFile(file)
+		if err != nil {
+			return nil, opts, err
+		}
+		resolved.EnvFiles = append(resolved.EnvFiles, file)
+		layers = append(layers, vars)
+	}
+	if len(resolved.EnvFiles) == 0 {
+		resolved.EnvFiles = nil
+	}
+
+	return Merge(append(layers, overrides)...), resolved, nil
+}
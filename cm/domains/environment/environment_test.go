@@ -0,0 +1,86 @@
+package environment
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+func TestParse(t *testing.T) {
+	data := `# database
+export DB_HOST=localhost
+DB_PORT = 5432 # default port
+EMPTY=
+SINGLE='$HOME # literal'
+DOUBLE="line one\nline \"two\"" # comment
+URL=http://example.com/#anchor
+`
+	got, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []string{
+		"DB_HOST=localhost",
+		"DB_PORT=5432",
+		"EMPTY=",
+		"SINGLE=$HOME # literal",
+		"DOUBLE=line one\nline \"two\"",
+		"URL=http://example.com/#anchor",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{"NOVALUE", "1ABC=x", "A='open", `A="open`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("API_KEY=from-file\nPORT=3000\n"), 0644)
+	os.WriteFile(filepath.Join(dir, ".env.local"), []byte("PORT=3001\n"), 0644)
+
+	server := []string{"HOME=/home/me", "AWS_SECRET_ACCESS_KEY=x", "AWS_REGION=eu", "GITHUB_TOKEN=y", "TERM=screen"}
+	agent := []string{"TERM=xterm-256color"}
+	opts := session.EnvOptions{
+		Env:      []session.EnvVar{{Name: "PORT", Value: "4000"}, {Name: "TOKEN", Value: "s3cret", Secret: true}},
+		EnvFiles: []string{".env", filepath.Join(dir, ".env.local")},
+		EnvDeny:  []string{"AWS_*", "GITHUB_TOKEN"},
+	}
+
+	env, resolved, err := Build(server, agent, opts, dir)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	want := []string{"HOME=/home/me", "TERM=xterm-256color", "API_KEY=from-file", "PORT=4000", "TOKEN=s3cret"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("env = %q, want %q", env, want)
+	}
+	if resolved.EnvFiles[0] != filepath.Join(dir, ".env") {
+		t.Errorf("env files = %q", resolved.EnvFiles)
+	}
+
+	data, _ := json.Marshal(resolved)
+	if strings.Contains(string(data), "s3cret") || !strings.Contains(string(data), session.MaskedValue) {
+		t.Errorf("secret not masked: %s", data)
+	}
+
+	for _, bad := range []session.EnvOptions{
+		{Env: []session.EnvVar{{Name: "BAD-NAME"}}},
+		{Env: []session.EnvVar{{Name: "A", Value: "x\x00"}}},
+		{EnvDeny: []string{"A*B"}},
+		{EnvFiles: []string{"missing.env"}},
+	} {
+		if _, _, err := Build(server, agent, bad, dir); err == nil {
+			t.Errorf("Build(%+v) should fail", bad)
+		}
+	}
+}
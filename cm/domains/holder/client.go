@@ -0,0 +1,272 @@
+package holder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/user/claude-manager/domains/terminal"
+)
+
+// dialTimeout bounds connecting to a holder and reading its hello
+const dialTimeout = 5 * time.Second
+
+// Command returns the command that runs a holder listening on socket. By
+// default it is this executable with the -hold flag.
+var Command = func(socket string) *exec.Cmd {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	return exec.Command(exe, "-hold", socket)
+}
+
+// Client is a manager's connection to a holder. It is the terminal.Agent
+// for the agent the holder keeps.
+type Client struct {
+	Info Info
+
+	conn   net.Conn
+	wmu    sync.Mutex
+	output *io.PipeReader
+
+	exitOnce sync.Once
+	exited   chan struct{} // closed once the exit is known or the holder is lost
+	exit     Exit
+	lost     error
+	closed   chan struct{} // closed when the holder closes the connection
+
+	killMu   sync.Mutex
+	kills    chan string
+	detached atomic.Bool
+}
+
+// Start launches a holder in dir for the agent described by spec, and
+// connects to it once the agent has started
+func Start(dir string, spec Spec) (*Client, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	socket := SocketPath(dir, spec.ID)
+	payload, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	logFile, err := os.OpenFile(logPath(socket), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+
+	cmd := Command(socket)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stderr = logFile
+	// A session of its own keeps the holder out of reach of signals meant
+	// for this process, such as an interrupt from its terminal
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	status, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	reply, _ := io.ReadAll(status)
+	// The holder is reaped here while this process lives; after that it is
+	// inherited by init
+	go cmd.Wait()
+	if result := strings.TrimSpace(string(reply)); result != "ok" {
+		os.Remove(logPath(socket))
+		if result == "" {
+			result = "holder exited"
+		}
+		return nil, errors.New(result)
+	}
+	return Dial(socket)
+}
+
+// Dial connects to a running holder and streams the agent's output from
+// the oldest the holder retains
+func Dial(socket string) (*Client, error) {
+	nc, err := net.DialTimeout("unix", socket, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	nc.SetReadDeadline(time.Now().Add(dialTimeout))
+	kind, payload, err := readFrame(nc)
+	if err == nil && kind != frameHello {
+		err = fmt.Errorf("unexpected frame %q", kind)
+	}
+	c := &Client{
+		conn:   nc,
+		exited: make(chan struct{}),
+		closed: make(chan struct{}),
+		kills:  make(chan string, 1),
+	}
+	if err == nil {
+		err = json.Unmarshal(payload, &c.Info)
+	}
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("holder %s: %w", socket, err)
+	}
+	nc.SetReadDeadline(time.Time{})
+
+	reader, writer := io.Pipe()
+	c.output = reader
+	go c.receive(writer)
+
+	offset := make([]byte, 8)
+	binary.BigEndian.PutUint64(offset, uint64(c.Info.Offset))
+	if err := c.send(frameAttach, offset); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// receive handles frames from the holder until it closes the connection
+func (c *Client) receive(output *io.PipeWriter) {
+	defer close(c.closed)
+	for {
+		kind, payload, err := readFrame(c.conn)
+		if err != nil {
+			if c.detached.Load() {
+				err = terminal.ErrDetached
+			}
+			c.exitOnce.Do(func() {
+				c.lost = err
+				close(c.exited)
+			})
+			output.CloseWithError(err)
+			return
+		}
+
+		switch kind {
+		case frameOutput:
+			if len(payload) > 8 {
+				output.Write(payload[8:])
+			}
+		case frameKilled:
+			select {
+			case c.kills <- string(payload):
+			default:
+			}
+		case frameExit:
+			c.exitOnce.Do(func() {
+				if err := json.Unmarshal(payload, &c.exit); err != nil {
+					c.lost = err
+				}
+				close(c.exited)
+			})
+			output.Close()
+		}
+	}
+}
+
+func (c *Client) send(kind byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return writeFrame(c.conn, kind, payload)
+}
+
+// Read reads the agent's output
+func (c *Client) Read(p []byte) (int, error) {
+	return c.output.Read(p)
+}
+
+// Write writes input to the agent's terminal
+func (c *Client) Write(p []byte) (int, error) {
+	for sent := 0; sent < len(p); {
+		n := min(len(p)-sent, maxFrame)
+		if err := c.send(frameInput, p[sent:sent+n]); err != nil {
+			return sent, err
+		}
+		sent += n
+	}
+	return len(p), nil
+}
+
+// Resize sets the terminal's window size
+func (c *Client) Resize(rows, cols uint16) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint16(payload, rows)
+	binary.BigEndian.PutUint16(payload[2:], cols)
+	return c.send(frameResize, payload)
+}
+
+// PID returns the agent's process ID
+func (c *Client) PID() int {
+	return c.Info.PID
+}
+
+// Wait blocks until the holder reports that the agent has exited, then
+// lets the holder go
+func (c *Client) Wait() (int, string, error) {
+	<-c.exited
+	if c.lost != nil {
+		if errors.Is(c.lost, terminal.ErrDetached) {
+			return -1, "", c.lost
+		}
+		return -1, "", fmt.Errorf("lost holder: %w", c.lost)
+	}
+
+	c.send(frameRelease, nil)
+	select {
+	case <-c.closed:
+	case <-time.After(dialTimeout):
+	}
+	c.conn.Close()
+
+	var err error
+	if c.exit.Error != "" {
+		err = errors.New(c.exit.Error)
+	}
+	return c.exit.Code, c.exit.Signal, err
+}
+
+// Kill asks the holder to terminate the agent and everything it started
+func (c *Client) Kill(grace time.Duration) error {
+	c.killMu.Lock()
+	defer c.killMu.Unlock()
+
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, uint64(grace))
+	if err := c.send(frameKill, payload); err != nil {
+		return err
+	}
+	select {
+	case reply := <-c.kills:
+		if reply != "" {
+			return errors.New(reply)
+		}
+		return nil
+	case <-c.exited:
+		return nil
+	}
+}
+
+// Close closes the connection to the holder
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Detach closes the connection to the holder, leaving the agent running
+// for a later manager to adopt
+func (c *Client) Detach() error {
+	c.detached.Store(true)
+	return c.conn.Close()
+}
@@ -0,0 +1,374 @@
+// Package holder keeps an agent's PTY and process in a small process of
+// their own, like dtach or abduco, so that they outlive the manager that
+// launched them. The manager talks to the holder over a Unix socket and a
+// manager started later can adopt the agent again.
+package holder
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+
+	"github.com/user/claude-manager/domains/process"
+	"github.com/user/claude-manager/domains/terminal"
+)
+
+// Spec is everything a holder needs to start an agent
+type Spec struct {
+	ID      string   `json:"id"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Dir     string   `json:"dir"`
+	Rows    uint16   `json:"rows"`
+	Cols    uint16   `json:"cols"`
+	Cgroup  string   `json:"cgroup,omitempty"` // cgroup to start the agent in
+
+	Offset     int64 `json:"offset"`     // where the agent's output stream starts
+	Scrollback int   `json:"scrollback"` // bytes of output kept for a manager to replay
+
+	// Meta is kept for the manager, which records the session in it
+	Meta json.RawMessage `json:"meta,omitempty"`
+}
+
+// replayChunk bounds the output sent in one frame when a manager attaches
+const replayChunk = 64 * 1024
+
+// writeTimeout bounds how long a frame may take to reach the manager. A
+// manager that stops reading is dropped, rather than holding up the agent
+// and any manager that would replace it.
+var writeTimeout = 10 * time.Second
+
+// holder serves one agent
+type holder struct {
+	spec     Spec
+	socket   string
+	listener net.Listener
+	pty      *os.File
+	cmd      *exec.Cmd
+	started  time.Time
+
+	mu       sync.Mutex
+	output   *terminal.Scrollback
+	conn     *conn // the attached manager, if any
+	exit     *Exit // set once the agent has exited and been reaped
+	release  chan struct{}
+	released bool
+
+	teardown sync.Mutex // held while the agent's processes are killed
+	reaped   bool
+}
+
+// conn is a manager's connection to the holder
+type conn struct {
+	net.Conn
+	wmu       sync.Mutex
+	streaming bool // output is sent once the manager has attached
+}
+
+func (c *conn) send(kind byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return writeFrame(c.Conn, kind, payload)
+}
+
+func (c *conn) sendJSON(kind byte, v interface{}) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return writeJSON(c.Conn, kind, v)
+}
+
+// Serve runs a holder. It reads the agent's Spec from spec, starts the
+// agent, reports "ok" or why it could not on status, and then serves the
+// agent on socket until it has exited and a manager has recorded how.
+func Serve(socket string, spec io.Reader, status io.WriteCloser) error {
+	h := &holder{release: make(chan struct{})}
+	err := h.start(socket, spec)
+	if err != nil {
+		fmt.Fprintln(status, err)
+	} else {
+		fmt.Fprintln(status, "ok")
+	}
+	status.Close()
+	if err != nil {
+		return err
+	}
+	return h.serve()
+}
+
+// start listens on the socket and starts the agent
+func (h *holder) start(socket string, r io.Reader) error {
+	if err := json.NewDecoder(r).Decode(&h.spec); err != nil {
+		return fmt.Errorf("invalid spec: %w", err)
+	}
+	h.output = terminal.NewScrollback(h.spec.Scrollback)
+	h.output.Reset(h.spec.Offset)
+
+	listener, err := listen(socket)
+	if err != nil {
+		return err
+	}
+	h.socket = socket
+	h.listener = listener
+
+	cmd := exec.Command(h.spec.Command, h.spec.Args...)
+	cmd.Dir = h.spec.Dir
+	cmd.Env = h.spec.Env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if h.spec.Cgroup != "" {
+		dir, err := os.Open(h.spec.Cgroup)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		defer dir.Close()
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(dir.Fd())
+	}
+
+	ptyFile, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: h.spec.Rows, Cols: h.spec.Cols})
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	h.pty = ptyFile
+	h.cmd = cmd
+	h.started = time.Now()
+	return nil
+}
+
+// listen listens on a holder's socket, replacing any left by a holder
+// that has gone
+func listen(socket string) (net.Listener, error) {
+	if c, err := net.Dial("unix", socket); err == nil {
+		c.Close()
+		return nil, fmt.Errorf("%s is already held", socket)
+	}
+	os.Remove(socket)
+	return net.Listen("unix", socket)
+}
+
+// serve relays the agent's terminal until a manager has been told how it
+// ended
+func (h *holder) serve() error {
+	go h.accept(h.listener)
+
+	relayed := make(chan struct{})
+	go func() {
+		h.relayOutput()
+		close(relayed)
+	}()
+	h.wait(relayed)
+
+	<-h.release
+	h.listener.Close()
+	os.Remove(h.socket)
+	os.Remove(logPath(h.socket))
+	h.mu.Lock()
+	if h.conn != nil {
+		h.conn.Close()
+	}
+	h.mu.Unlock()
+	return nil
+}
+
+// relayOutput keeps the agent's output and sends it to the manager
+func (h *holder) relayOutput() {
+	buffer := make([]byte, 4096)
+	for {
+		n, err := h.pty.Read(buffer)
+		if n > 0 {
+			h.mu.Lock()
+			offset := h.output.Write(buffer[:n])
+			if c := h.conn; c != nil && c.streaming {
+				if c.send(frameOutput, outputFrame(offset, buffer[:n])) != nil {
+					h.detach(c)
+				}
+			}
+			h.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wait reaps the agent once anything it left running is gone and its
+// output has been relayed, then tells the manager how it ended
+func (h *holder) wait(relayed <-chan struct{}) {
+	pid := h.cmd.Process.Pid
+	// Tear down before reaping, so the leader's PID cannot be reused by
+	// then
+	process.WaitExited(pid)
+	h.teardown.Lock()
+	err := process.KillTree(pid, process.DefaultGrace)
+	h.cmd.Wait()
+	h.reaped = true
+	h.teardown.Unlock()
+
+	// Every process holding the terminal is gone, so its output ends soon
+	select {
+	case <-relayed:
+	case <-time.After(time.Second):
+	}
+	h.pty.Close()
+
+	code, signal := terminal.ExitStatus(h.cmd.ProcessState)
+	exit := &Exit{Code: code, Signal: signal}
+	if err != nil {
+		exit.Error = err.Error()
+	}
+	h.mu.Lock()
+	h.exit = exit
+	if c := h.conn; c != nil && c.streaming {
+		if c.sendJSON(frameExit, exit) != nil {
+			h.detach(c)
+		}
+	}
+	h.mu.Unlock()
+}
+
+// kill tears down the agent unless it has already been reaped
+func (h *holder) kill(grace time.Duration) error {
+	h.teardown.Lock()
+	defer h.teardown.Unlock()
+	if h.reaped {
+		return nil
+	}
+	return process.KillTree(h.cmd.Process.Pid, grace)
+}
+
+// accept takes manager connections. A new one replaces the last, which
+// may belong to a manager that has gone.
+func (h *holder) accept(listener net.Listener) {
+	for {
+		nc, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{Conn: nc}
+
+		h.mu.Lock()
+		if h.conn != nil {
+			h.conn.Close()
+		}
+		h.conn = c
+		info := Info{PID: h.cmd.Process.Pid, Started: h.started, Offset: h.output.Start(), Spec: h.spec}
+		if err := c.sendJSON(frameHello, info); err != nil {
+			h.detach(c)
+			h.mu.Unlock()
+			continue
+		}
+		h.mu.Unlock()
+		go h.handle(c)
+	}
+}
+
+// detach forgets a manager connection. Must be called with mu held.
+func (h *holder) detach(c *conn) {
+	c.Close()
+	if h.conn == c {
+		h.conn = nil
+	}
+}
+
+// handle serves requests from a manager
+func (h *holder) handle(c *conn) {
+	defer func() {
+		h.mu.Lock()
+		h.detach(c)
+		h.mu.Unlock()
+	}()
+
+	for {
+		kind, payload, err := readFrame(c)
+		if err != nil {
+			return
+		}
+		switch kind {
+		case frameAttach:
+			if len(payload) != 8 {
+				return
+			}
+			h.attach(c, int64(binary.BigEndian.Uint64(payload)))
+		case frameInput:
+			h.pty.Write(payload)
+		case frameResize:
+			if len(payload) != 4 {
+				return
+			}
+			rows, cols := binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])
+			pty.Setsize(h.pty, &pty.Winsize{Rows: rows, Cols: cols})
+		case frameKill:
+			if len(payload) != 8 {
+				return
+			}
+			grace := time.Duration(binary.BigEndian.Uint64(payload))
+			go func() {
+				var reply []byte
+				if err := h.kill(grace); err != nil {
+					reply = []byte(err.Error())
+				}
+				c.send(frameKilled, reply)
+			}()
+		case frameRelease:
+			h.mu.Lock()
+			release := h.exit != nil && !h.released
+			h.released = h.released || release
+			h.mu.Unlock()
+			if release {
+				// serve closes the connection once the socket is gone
+				close(h.release)
+			}
+		}
+	}
+}
+
+// attach sends a manager the output retained from offset, then streams
+// the rest as it arrives
+func (h *holder) attach(c *conn, offset int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, from := h.output.ReadFrom(offset)
+	for len(data) > 0 {
+		n := min(len(data), replayChunk)
+		if c.send(frameOutput, outputFrame(from, data[:n])) != nil {
+			h.detach(c)
+			return
+		}
+		data, from = data[n:], from+int64(n)
+	}
+	c.streaming = true
+	if h.exit != nil && c.sendJSON(frameExit, h.exit) != nil {
+		h.detach(c)
+	}
+}
+
+// Discover returns the sockets of the holders in dir
+func Discover(dir string) ([]string, error) {
+	return filepath.Glob(filepath.Join(dir, "*.sock"))
+}
+
+// SocketPath returns the socket a session's holder listens on
+func SocketPath(dir, id string) string {
+	return filepath.Join(dir, id+".sock")
+}
+
+// logPath returns the file a holder logs to
+func logPath(socket string) string {
+	return strings.TrimSuffix(socket, ".sock") + ".log"
+}
@@ -0,0 +1,169 @@
+package holder
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs the test binary as a holder when the tests start one
+func TestMain(m *testing.M) {
+	if socket := os.Getenv("HOLDER_TEST_SOCKET"); socket != "" {
+		writeTimeout = 200 * time.Millisecond
+		if err := Serve(socket, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	Command = func(socket string) *exec.Cmd {
+		cmd := exec.Command(os.Args[0])
+		cmd.Env = append(os.Environ(), "HOLDER_TEST_SOCKET="+socket)
+		return cmd
+	}
+	os.Exit(m.Run())
+}
+
+func startHolder(t *testing.T, dir string, script string) *Client {
+	t.Helper()
+	client, err := Start(dir, Spec{ID: "test", Command: "sh", Args: []string{"-c", script}, Dir: dir, Rows: 24, Cols: 80})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return client
+}
+
+// readUntil reads the agent's output until it contains want
+func readUntil(t *testing.T, r *bufio.Reader, want string) string {
+	t.Helper()
+	done := make(chan string, 1)
+	go func() {
+		var seen strings.Builder
+		for !strings.Contains(seen.String(), want) {
+			b, err := r.ReadByte()
+			if err != nil {
+				break
+			}
+			seen.WriteByte(b)
+		}
+		done <- seen.String()
+	}()
+	select {
+	case seen := <-done:
+		if !strings.Contains(seen, want) {
+			t.Fatalf("output %q does not contain %q", seen, want)
+		}
+		return seen
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %q", want)
+		return ""
+	}
+}
+
+func TestHolderExit(t *testing.T) {
+	dir := t.TempDir()
+	client := startHolder(t, dir, "echo hello; exit 3")
+	readUntil(t, bufio.NewReader(client), "hello")
+
+	code, signal, err := client.Wait()
+	if code != 3 || signal != "" || err != nil {
+		t.Errorf("Wait = %d, %q, %v; want 3", code, signal, err)
+	}
+	if sockets, _ := Discover(dir); len(sockets) != 0 {
+		t.Errorf("sockets left behind: %v", sockets)
+	}
+}
+
+func TestHolderReattach(t *testing.T) {
+	dir := t.TempDir()
+	first := startHolder(t, dir, `echo one; sleep 0.3; echo two; read line; echo "got $line"`)
+	readUntil(t, bufio.NewReader(first), "one")
+	if err := first.Detach(); err != nil {
+		t.Fatalf("Detach: %v", err)
+	}
+	if _, _, err := first.Wait(); err == nil {
+		t.Error("Wait on a detached client should fail")
+	}
+
+	// Output written while no manager is attached is kept for the next
+	time.Sleep(500 * time.Millisecond)
+	sockets, err := Discover(dir)
+	if err != nil || len(sockets) != 1 {
+		t.Fatalf("Discover = %v, %v", sockets, err)
+	}
+	second, err := Dial(sockets[0])
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if second.PID() != first.PID() || second.Info.Spec.ID != "test" {
+		t.Errorf("adopted PID %d spec %+v, want PID %d", second.PID(), second.Info.Spec, first.PID())
+	}
+	output := bufio.NewReader(second)
+	if seen := readUntil(t, output, "two"); !strings.Contains(seen, "one") {
+		t.Errorf("replay %q is missing earlier output", seen)
+	}
+
+	second.Write([]byte("hi\n"))
+	readUntil(t, output, "got hi")
+	if code, _, err := second.Wait(); code != 0 || err != nil {
+		t.Errorf("Wait = %d, %v", code, err)
+	}
+}
+
+func TestHolderDropsStalledManager(t *testing.T) {
+	dir := t.TempDir()
+	client := startHolder(t, dir, "read line; head -c 1000000 /dev/zero | tr '\\0' x; echo done; sleep 30")
+	defer client.Kill(time.Second)
+
+	// A manager that attaches and stops reading is dropped once the agent
+	// fills its connection
+	stalled, err := net.Dial("unix", SocketPath(dir, "test"))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer stalled.Close()
+	writeFrame(stalled, frameAttach, make([]byte, 8))
+	writeFrame(stalled, frameInput, []byte("go\n"))
+
+	time.Sleep(time.Second)
+	next, err := Dial(SocketPath(dir, "test"))
+	if err != nil {
+		t.Fatalf("Dial after a stalled manager: %v", err)
+	}
+	output := bufio.NewReader(next)
+	for {
+		line, err := output.ReadString('\n')
+		if err != nil {
+			t.Fatalf("output ended before the agent finished: %v", err)
+		}
+		if strings.Contains(line, "done") {
+			break
+		}
+	}
+}
+
+func TestHolderKill(t *testing.T) {
+	client := startHolder(t, t.TempDir(), "sleep 30")
+	if err := client.Kill(time.Second); err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+	if code, signal, _ := client.Wait(); code != -1 || signal != "SIGTERM" {
+		t.Errorf("Wait = %d, %q; want SIGTERM", code, signal)
+	}
+}
+
+func TestHolderStartFailure(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Start(dir, Spec{ID: "test", Command: "/nonexistent/agent", Dir: dir})
+	if err == nil {
+		t.Fatal("Start should fail")
+	}
+	if sockets, _ := Discover(dir); len(sockets) != 0 {
+		t.Errorf("sockets left behind: %v", sockets)
+	}
+}
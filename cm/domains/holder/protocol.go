@@ -0,0 +1,83 @@
+package holder
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Frames exchanged over a holder's socket. Each is a type byte, a
+// big-endian uint32 payload length and the payload.
+const (
+	// From the manager
+	frameAttach  = 'a' // uint64 offset to stream output from
+	frameInput   = 'i' // bytes to write to the terminal
+	frameResize  = 'r' // uint16 rows, uint16 cols
+	frameKill    = 'k' // int64 grace period in nanoseconds
+	frameRelease = 'x' // the exit has been recorded, so the holder may go
+
+	// From the holder
+	frameHello  = 'h' // Info as JSON
+	frameOutput = 'o' // uint64 offset of the first byte, then the bytes
+	frameKilled = 'K' // empty, or why the agent could not be killed
+	frameExit   = 'e' // Exit as JSON
+)
+
+// maxFrame bounds a frame's payload
+const maxFrame = 1 << 20
+
+// Info describes a held agent
+type Info struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	Offset  int64     `json:"offset"` // oldest output still retained
+	Spec    Spec      `json:"spec"`
+}
+
+// Exit is how a held agent ended
+type Exit struct {
+	Code   int    `json:"code"`
+	Signal string `json:"signal,omitempty"`
+	Error  string `json:"error,omitempty"` // teardown problems
+}
+
+func writeFrame(w io.Writer, kind byte, payload []byte) error {
+	header := make([]byte, 5, 5+len(payload))
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+func writeJSON(w io.Writer, kind byte, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFrame(w, kind, payload)
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrame {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// outputFrame encodes output starting at offset
+func outputFrame(offset int64, data []byte) []byte {
+	payload := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(payload, uint64(offset))
+	return append(payload, data...)
+}
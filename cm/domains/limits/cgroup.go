@@ -0,0 +1,150 @@
+package limits
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Where cgroup v2 is mounted and where this process's membership is listed
+var (
+	cgroupMount = "/sys/fs/cgroup"
+	selfCgroup  = "/proc/self/cgroup"
+)
+
+// cgroupPeriod is the cpu.max period in microseconds
+const cgroupPeriod = 100000
+
+// errNoCgroups is returned when cgroup v2 is not mounted
+var errNoCgroups = errors.New("cgroup v2 is not mounted")
+
+var (
+	setupOnce   sync.Once
+	sessionsDir string // parent of every session's cgroup
+	controllers map[string]bool
+	setupErr    error
+)
+
+// sessionsCgroup returns the cgroup under which session cgroups are made,
+// creating it and enabling controllers on first use
+func sessionsCgroup() (string, map[string]bool, error) {
+	setupOnce.Do(func() {
+		sessionsDir, controllers, setupErr = setupCgroups()
+	})
+	return sessionsDir, controllers, setupErr
+}
+
+// setupCgroups creates a "sessions" cgroup beside this process's own. A
+// cgroup with processes in it cannot enable controllers for its children,
+// so if necessary this process first moves into a "manager" leaf.
+func setupCgroups() (string, map[string]bool, error) {
+	if _, err := os.Stat(filepath.Join(cgroupMount, "cgroup.controllers")); err != nil {
+		return "", nil, errNoCgroups
+	}
+	own, err := ownCgroup()
+	if err != nil {
+		return "", nil, err
+	}
+	dir := filepath.Join(cgroupMount, own)
+
+	available, err := readControllers(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = enableControllers(dir, available)
+	if errors.Is(err, syscall.EBUSY) {
+		leaf := filepath.Join(dir, "manager")
+		if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+			return "", nil, err
+		}
+		if err := writeFile(leaf, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+			return "", nil, fmt.Errorf("cannot move into %s: %w", leaf, err)
+		}
+		err = enableControllers(dir, available)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot enable controllers in %s: %w", dir, err)
+	}
+
+	sessions := filepath.Join(dir, "sessions")
+	if err := os.Mkdir(sessions, 0755); err != nil && !os.IsExist(err) {
+		return "", nil, err
+	}
+	if err := enableControllers(sessions, available); err != nil {
+		return "", nil, fmt.Errorf("cannot enable controllers in %s: %w", sessions, err)
+	}
+	return sessions, available, nil
+}
+
+// ownCgroup returns this process's cgroup v2 path from /proc/self/cgroup
+func ownCgroup() (string, error) {
+	file, err := os.Open(selfCgroup)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", errNoCgroups
+}
+
+// readControllers reads a space separated list of controllers
+func readControllers(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, name := range strings.Fields(string(data)) {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// enableControllers makes the cpu, memory and pids controllers, where
+// available, usable by a cgroup's children
+func enableControllers(dir string, available map[string]bool) error {
+	var enable []string
+	for _, name := range []string{"cpu", "memory", "pids"} {
+		if available[name] {
+			enable = append(enable, "+"+name)
+		}
+	}
+	if len(enable) == 0 {
+		return nil
+	}
+	return writeFile(dir, "cgroup.subtree_control", strings.Join(enable, " "))
+}
+
+// writeFile writes a cgroup interface file
+func writeFile(dir, name, value string) error {
+	return os.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// readKeyed reads a flat keyed file such as memory.events
+func readKeyed(path string) map[string]uint64 {
+	values := make(map[string]uint64)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return values
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			values[fields[0]], _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return values
+}
@@ -0,0 +1,264 @@
+package limits
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+// How a session's limits are enforced
+const (
+	ModeCgroup = "cgroup" // a cgroup v2 of its own, plus the nice level
+	ModeRlimit = "rlimit" // per process rlimits and the nice level only
+)
+
+// Group enforces a session's limits. With cgroups every process the session
+// starts is confined to the session's cgroup. Without them only the memory
+// ceiling and nice level can be applied, per process, through rlimits.
+type Group struct {
+	Mode string
+	Path string // the session's cgroup, if any
+
+	limits   session.Limits
+	dir      *os.File          // the cgroup directory, open until Started
+	counts   map[string]uint64 // event counters already reported
+	warnings []string          // limits that could not be applied as asked
+}
+
+// Prepare creates the cgroup for a session with the given limits, falling
+// back to rlimits when cgroup v2 or the controllers the limits need are not
+// available to this process. It returns nil if no limits are set.
+func Prepare(id string, limits session.Limits) (*Group, error) {
+	if limits.IsZero() {
+		return nil, nil
+	}
+	if err := limits.Validate(); err != nil {
+		return nil, err
+	}
+
+	group := &Group{limits: limits, counts: make(map[string]uint64)}
+	if len(controllersFor(limits)) == 0 {
+		group.Mode = ModeRlimit
+		return group, nil
+	}
+
+	err := group.createCgroup(id)
+	if err == nil {
+		group.Mode = ModeCgroup
+		return group, nil
+	}
+
+	group.Mode = ModeRlimit
+	group.warn("cgroups unavailable, using rlimits: %v", err)
+	if limits.CPUWeight > 0 || limits.CPUQuota > 0 {
+		group.warn("CPU limits are not enforced without cgroups")
+	}
+	if limits.PidsMax > 0 {
+		group.warn("the process limit is not enforced without cgroups")
+	}
+	if limits.MemoryMax > 0 {
+		group.warn("the memory ceiling applies to each process separately without cgroups")
+	}
+	return group, nil
+}
+
+// Adopt returns the group enforcing limits on a session whose agent was
+// started by an earlier run of this process, in the given mode. Its
+// cgroup, if it had one, is found again by the session's ID.
+func Adopt(id string, limits session.Limits, mode string) *Group {
+	if limits.IsZero() {
+		return nil
+	}
+	group := &Group{Mode: mode, limits: limits, counts: make(map[string]uint64)}
+	if mode == ModeCgroup {
+		if parent, _, err := sessionsCgroup(); err == nil {
+			group.Path = filepath.Join(parent, id)
+		}
+	}
+	return group
+}
+
+// controllersFor lists the cgroup controllers needed to enforce limits.
+// The nice level needs none.
+func controllersFor(l session.Limits) []string {
+	var names []string
+	if l.CPUWeight > 0 || l.CPUQuota > 0 {
+		names = append(names, "cpu")
+	}
+	if l.MemoryMax > 0 {
+		names = append(names, "memory")
+	}
+	if l.PidsMax > 0 {
+		names = append(names, "pids")
+	}
+	return names
+}
+
+// createCgroup makes the session's cgroup and writes its limits
+func (g *Group) createCgroup(id string) error {
+	parent, available, err := sessionsCgroup()
+	if err != nil {
+		return err
+	}
+	for _, need := range controllersFor(g.limits) {
+		if !available[need] {
+			return fmt.Errorf("the %s controller is not delegated", need)
+		}
+	}
+
+	// A relaunched session may find its last cgroup still there
+	path := filepath.Join(parent, id)
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	g.Path = path
+
+	if err := g.writeLimits(); err != nil {
+		g.Remove()
+		return err
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		g.Remove()
+		return err
+	}
+	g.dir = dir
+	return nil
+}
+
+// writeLimits writes the limits to the cgroup's interface files
+func (g *Group) writeLimits() error {
+	l := g.limits
+	if l.CPUWeight > 0 {
+		if err := writeFile(g.Path, "cpu.weight", strconv.Itoa(l.CPUWeight)); err != nil {
+			return err
+		}
+	}
+	if l.CPUQuota > 0 {
+		quota := max(int(l.CPUQuota*cgroupPeriod), 1000)
+		if err := writeFile(g.Path, "cpu.max", fmt.Sprintf("%d %d", quota, cgroupPeriod)); err != nil {
+			return err
+		}
+	}
+	if l.MemoryMax > 0 {
+		if err := writeFile(g.Path, "memory.max", strconv.FormatInt(l.MemoryMax, 10)); err != nil {
+			return err
+		}
+	}
+	if l.PidsMax > 0 {
+		if err := writeFile(g.Path, "pids.max", strconv.Itoa(l.PidsMax)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Configure arranges for cmd to start inside the session's cgroup. It must
+// be called after any other change to cmd.SysProcAttr.
+func (g *Group) Configure(cmd *exec.Cmd) {
+	if g == nil || g.dir == nil {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(g.dir.Fd())
+}
+
+// Started applies the limits that are set per process to the session's
+// leader, which started its own process group. Anything the leader started
+// before this call is not covered by them.
+func (g *Group) Started(pid int) {
+	if g == nil {
+		return
+	}
+	if g.dir != nil {
+		g.dir.Close()
+		g.dir = nil
+	}
+
+	if g.limits.Nice != 0 {
+		if err := setNice(pid, g.limits.Nice); err != nil {
+			g.warn("cannot set nice level %d: %v", g.limits.Nice, err)
+		}
+	}
+	if g.Mode == ModeRlimit && g.limits.MemoryMax > 0 {
+		if err := setRlimit(pid, syscall.RLIMIT_DATA, uint64(g.limits.MemoryMax)); err != nil {
+			g.warn("cannot limit memory: %v", err)
+		}
+	}
+}
+
+// Breaches returns a description of each limit the session has run into
+// since the last call. Only cgroups report breaches.
+func (g *Group) Breaches() []string {
+	if g == nil || g.Mode != ModeCgroup {
+		return nil
+	}
+
+	var breaches []string
+	check := func(file, key, format string) {
+		count := readKeyed(filepath.Join(g.Path, file))[key]
+		seen := g.counts[file+"/"+key]
+		if count > seen {
+			g.counts[file+"/"+key] = count
+			breaches = append(breaches, fmt.Sprintf(format, count-seen))
+		}
+	}
+	check("memory.events", "oom_kill", "memory limit reached: %d processes killed")
+	check("memory.events", "max", "memory limit reached %d times")
+	check("pids.events", "max", "process limit reached: %d forks refused")
+	return breaches
+}
+
+// Remove deletes the session's cgroup once its processes have gone. Exited
+// processes keep the cgroup busy until they are reaped, so it retries for a
+// short while.
+func (g *Group) Remove() error {
+	if g == nil || g.Path == "" {
+		return nil
+	}
+	if g.dir != nil {
+		g.dir.Close()
+		g.dir = nil
+	}
+
+	var err error
+	for i := 0; i < 20; i++ {
+		err = os.Remove(g.Path)
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return err
+}
+
+// Limits returns the limits the group enforces
+func (g *Group) Limits() session.Limits {
+	return g.limits
+}
+
+// TakeWarnings returns the limits that could not be applied as asked since
+// the last call
+func (g *Group) TakeWarnings() []string {
+	if g == nil {
+		return nil
+	}
+	warnings := g.warnings
+	g.warnings = nil
+	return warnings
+}
+
+// warn records a limit that could not be applied as asked
+func (g *Group) warn(format string, args ...interface{}) {
+	g.warnings = append(g.warnings, fmt.Sprintf(format, args...))
+}
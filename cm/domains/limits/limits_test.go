@@ -0,0 +1,170 @@
+package limits
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+// fakeCgroups points the package at a directory laid out like a cgroup v2
+// mount offering the given controllers
+func fakeCgroups(t *testing.T, available string) string {
+	t.Helper()
+	mount := t.TempDir()
+	own := filepath.Join(mount, "user.slice", "manager.service")
+	for _, dir := range []string{mount, own} {
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte(available+"\n"), 0644)
+	}
+	self := filepath.Join(mount, "self")
+	os.WriteFile(self, []byte("0::/user.slice/manager.service\n"), 0644)
+
+	oldMount, oldSelf := cgroupMount, selfCgroup
+	cgroupMount, selfCgroup = mount, self
+	setupOnce = sync.Once{}
+	t.Cleanup(func() {
+		cgroupMount, selfCgroup = oldMount, oldSelf
+		setupOnce = sync.Once{}
+	})
+	return own
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestPrepareCgroup(t *testing.T) {
+	own := fakeCgroups(t, "cpuset cpu io memory pids")
+
+	group, err := Prepare("s1", session.Limits{CPUWeight: 50, CPUQuota: 1.5, MemoryMax: 1 << 30, PidsMax: 100})
+	if err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	defer group.dir.Close()
+
+	if group.Mode != ModeCgroup || group.Path != filepath.Join(own, "sessions", "s1") {
+		t.Fatalf("group = %+v", group)
+	}
+	for file, want := range map[string]string{
+		"cpu.weight": "50",
+		"cpu.max":    "150000 100000",
+		"memory.max": "1073741824",
+		"pids.max":   "100",
+	} {
+		if got := readFile(t, filepath.Join(group.Path, file)); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+	if got := readFile(t, filepath.Join(own, "sessions", "cgroup.subtree_control")); got != "+cpu +memory +pids" {
+		t.Errorf("subtree_control = %q", got)
+	}
+
+	cmd := exec.Command("true")
+	group.Configure(cmd)
+	if !cmd.SysProcAttr.UseCgroupFD {
+		t.Error("command is not started in the cgroup")
+	}
+
+	os.WriteFile(filepath.Join(group.Path, "memory.events"), []byte("low 0\nhigh 0\nmax 5\noom 1\noom_kill 1\n"), 0644)
+	if breaches := group.Breaches(); len(breaches) != 2 {
+		t.Errorf("breaches = %q", breaches)
+	}
+	if breaches := group.Breaches(); len(breaches) != 0 {
+		t.Errorf("breaches reported twice: %q", breaches)
+	}
+	os.WriteFile(filepath.Join(group.Path, "pids.events"), []byte("max 3\n"), 0644)
+	if breaches := group.Breaches(); len(breaches) != 1 || !strings.Contains(breaches[0], "3 forks") {
+		t.Errorf("breaches = %q", breaches)
+	}
+}
+
+func TestPrepareFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		available string // controllers, or "" for no cgroup v2 at all
+		limits    session.Limits
+		warnings  int
+	}{
+		{name: "no cgroups", limits: session.Limits{CPUQuota: 1, PidsMax: 10, MemoryMax: 1 << 20}, warnings: 4},
+		{name: "controller not delegated", available: "cpu memory", limits: session.Limits{PidsMax: 10}, warnings: 2},
+		{name: "nice only", available: "cpu memory pids", limits: session.Limits{Nice: 10}},
+	}
+	for _, tt := range tests {
+		if tt.available == "" {
+			fakeCgroups(t, "")
+			cgroupMount = filepath.Join(t.TempDir(), "missing")
+		} else {
+			fakeCgroups(t, tt.available)
+		}
+
+		group, err := Prepare("s1", tt.limits)
+		if err != nil {
+			t.Fatalf("%s: Prepare: %v", tt.name, err)
+		}
+		if group.Mode != ModeRlimit || group.Path != "" {
+			t.Errorf("%s: group = %+v", tt.name, group)
+		}
+		if warnings := group.TakeWarnings(); len(warnings) != tt.warnings {
+			t.Errorf("%s: warnings = %q", tt.name, warnings)
+		}
+	}
+}
+
+func TestPrepareInvalid(t *testing.T) {
+	if group, err := Prepare("s1", session.Limits{}); group != nil || err != nil {
+		t.Errorf("no limits: group = %+v, err = %v", group, err)
+	}
+	for _, limits := range []session.Limits{
+		{CPUWeight: 10001},
+		{CPUQuota: -1},
+		{MemoryMax: -1},
+		{PidsMax: -1},
+		{Nice: 20},
+	} {
+		if _, err := Prepare("s1", limits); err == nil {
+			t.Errorf("Prepare(%+v) should fail", limits)
+		}
+	}
+}
+
+func TestStartedRlimit(t *testing.T) {
+	fakeCgroups(t, "")
+	cgroupMount = filepath.Join(t.TempDir(), "missing")
+
+	cmd := exec.Command("sleep", "30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	group, _ := Prepare("s1", session.Limits{MemoryMax: 1 << 30, Nice: 5})
+	group.TakeWarnings()
+	group.Started(cmd.Process.Pid)
+	if warnings := group.TakeWarnings(); len(warnings) != 0 {
+		t.Fatalf("warnings = %q", warnings)
+	}
+
+	if limits := readFile(t, filepath.Join("/proc", strconv.Itoa(cmd.Process.Pid), "limits")); !strings.Contains(limits, "Max data size             1073741824") {
+		t.Errorf("limits = %s", limits)
+	}
+	// The raw syscall reports 20 - nice
+	if prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, cmd.Process.Pid); err != nil || prio != 15 {
+		t.Errorf("priority = %d, %v", prio, err)
+	}
+}
@@ -0,0 +1,21 @@
+package limits
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// setRlimit sets a resource limit of another process
+func setRlimit(pid, resource int, limit uint64) error {
+	rlim := syscall.Rlimit{Cur: limit, Max: limit}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&rlim)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setNice sets the scheduling priority of every process in a group
+func setNice(pgid, nice int) error {
+	return syscall.Setpriority(syscall.PRIO_PGRP, pgid, nice)
+}
@@ -0,0 +1,77 @@
+package process
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the socket state of a listening TCP socket in /proc/net/tcp
+const tcpListen = "0A"
+
+// listeningSockets maps the inode of every listening TCP socket to its port
+func listeningSockets() map[uint64]int {
+	sockets := make(map[uint64]int)
+	for _, name := range []string{"tcp", "tcp6"} {
+		file, err := os.Open(filepath.Join(procDir, "net", name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != tcpListen {
+				continue
+			}
+			colon := strings.LastIndexByte(fields[1], ':')
+			port, err := strconv.ParseUint(fields[1][colon+1:], 16, 16)
+			if err != nil {
+				continue
+			}
+			inode, err := strconv.ParseUint(fields[9], 10, 64)
+			if err != nil || inode == 0 {
+				continue
+			}
+			sockets[inode] = int(port)
+		}
+		file.Close()
+	}
+	return sockets
+}
+
+// ports returns the listening ports among a process's open files. Files of
+// processes owned by other users cannot be read and yield no ports.
+func ports(pid int, sockets map[uint64]int) []int {
+	if len(sockets) == 0 {
+		return nil
+	}
+	dir := filepath.Join(procDir, strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	var found []int
+	for _, entry := range entries {
+		link, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if port, ok := sockets[inode]; ok && !seen[port] {
+			seen[port] = true
+			found = append(found, port)
+		}
+	}
+	sort.Ints(found)
+	return found
+}
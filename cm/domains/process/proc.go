@@ -0,0 +1,147 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procDir is where the proc filesystem is mounted
+var procDir = "/proc"
+
+// Stat holds the fields of /proc/<pid>/stat this package uses
+type Stat struct {
+	PID       int
+	Comm      string
+	State     byte
+	PPID      int
+	PGID      int
+	SID       int
+	TPGID     int    // foreground process group of the controlling terminal
+	UTime     uint64 // clock ticks spent in user mode
+	STime     uint64 // clock ticks spent in kernel mode
+	StartTime uint64 // clock ticks after boot the process started
+	RSS       int64  // resident set size in pages
+}
+
+// Exited reports whether the process has finished and is only waiting
+// to be reaped
+func (s Stat) Exited() bool {
+	return s.State == 'Z' || s.State == 'X'
+}
+
+// ReadStat reads /proc/<pid>/stat
+func ReadStat(pid int) (Stat, error) {
+	data, err := os.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return Stat{}, err
+	}
+	return parseStat(string(data))
+}
+
+// parseStat parses the contents of a stat file. The command name is in
+// parentheses and may itself contain spaces and parentheses.
+func parseStat(data string) (Stat, error) {
+	lparen := strings.IndexByte(data, '(')
+	rparen := strings.LastIndexByte(data, ')')
+	if lparen < 0 || rparen < lparen {
+		return Stat{}, fmt.Errorf("malformed stat: %q", data)
+	}
+
+	var stat Stat
+	var err error
+	if stat.PID, err = strconv.Atoi(strings.TrimSpace(data[:lparen])); err != nil {
+		return Stat{}, fmt.Errorf("malformed stat pid: %v", err)
+	}
+	stat.Comm = data[lparen+1 : rparen]
+
+	// Fields after the command, numbered from 3 as in proc(5)
+	fields := strings.Fields(data[rparen+1:])
+	if len(fields) < 22 {
+		return Stat{}, fmt.Errorf("malformed stat: only %d fields", len(fields)+2)
+	}
+	field := func(n int) string { return fields[n-3] }
+
+	stat.State = field(3)[0]
+	stat.PPID, _ = strconv.Atoi(field(4))
+	stat.PGID, _ = strconv.Atoi(field(5))
+	stat.SID, _ = strconv.Atoi(field(6))
+	stat.TPGID, _ = strconv.Atoi(field(8))
+	stat.UTime, _ = strconv.ParseUint(field(14), 10, 64)
+	stat.STime, _ = strconv.ParseUint(field(15), 10, 64)
+	stat.StartTime, _ = strconv.ParseUint(field(22), 10, 64)
+	stat.RSS, _ = strconv.ParseInt(field(24), 10, 64)
+	return stat, nil
+}
+
+// List reads the stat of every process. Processes that exit while the
+// list is being read are skipped.
+func List() ([]Stat, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]Stat, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if stat, err := ReadStat(pid); err == nil {
+			stats = append(stats, stat)
+		}
+	}
+	return stats, nil
+}
+
+// Alive reports whether a process exists and has not exited
+func Alive(pid int) bool {
+	stat, err := ReadStat(pid)
+	return err == nil && !stat.Exited()
+}
+
+// Members returns the live processes belonging to the session led by
+// leader: the leader, its descendants, and anything still in its session
+// after its parent exited. Descendants that started sessions of their own
+// are included; orphans that did so cannot be traced and are not.
+func Members(leader int) ([]Stat, error) {
+	stats, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]int)
+	byPID := make(map[int]Stat, len(stats))
+	for _, stat := range stats {
+		byPID[stat.PID] = stat
+		children[stat.PPID] = append(children[stat.PPID], stat.PID)
+	}
+
+	seen := make(map[int]bool)
+	var members []Stat
+	var visit func(pid int)
+	visit = func(pid int) {
+		stat, exists := byPID[pid]
+		if !exists || seen[pid] {
+			return
+		}
+		seen[pid] = true
+		if !stat.Exited() {
+			members = append(members, stat)
+		}
+		for _, child := range children[pid] {
+			visit(child)
+		}
+	}
+
+	visit(leader)
+	for _, stat := range stats {
+		if stat.SID == leader || stat.PGID == leader {
+			visit(stat.PID)
+		}
+	}
+	return members, nil
+}
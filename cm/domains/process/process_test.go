@@ -0,0 +1,144 @@
+package process
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Stat
+		err  bool
+	}{
+		{
+			name: "plain",
+			data: "42 (bash) S 1 42 42 34816 42 4194560 1 2 3 4 17 5 6 7 20 0 1 0 9001 8192 300 18446744073709551615\n",
+			want: Stat{PID: 42, Comm: "bash", State: 'S', PPID: 1, PGID: 42, SID: 42, TPGID: 42, UTime: 17, STime: 5, StartTime: 9001, RSS: 300},
+		},
+		{
+			name: "command with spaces and parentheses",
+			data: "7 (a (b) c) Z 3 4 5 0 -1 0 0 0 0 0 1 2 0 0 20 0 1 0 77 0 0 0",
+			want: Stat{PID: 7, Comm: "a (b) c", State: 'Z', PPID: 3, PGID: 4, SID: 5, TPGID: -1, UTime: 1, STime: 2, StartTime: 77},
+		},
+		{name: "no command", data: "42 bash S 1", err: true},
+		{name: "truncated", data: "42 (bash) S 1 42 42", err: true},
+		{name: "bad pid", data: "x (bash) S 1 42 42 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseStat(tt.data)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: err = %v", tt.name, err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func startTree(t *testing.T, script string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sh: %v", err)
+	}
+	go cmd.Wait()
+
+	// Wait for the children to be running
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if members, _ := Members(cmd.Process.Pid); len(members) >= 3 {
+			return cmd
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("process tree did not start")
+	return nil
+}
+
+func TestKillTree(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "exits on SIGTERM", script: "sleep 30 & sleep 30 & wait"},
+		{name: "ignores SIGTERM", script: `trap "" TERM; sleep 30 & sleep 30 & wait`},
+	}
+	for _, tt := range tests {
+		cmd := startTree(t, tt.script)
+		members, _ := Members(cmd.Process.Pid)
+
+		if err := KillTree(cmd.Process.Pid, 200*time.Millisecond); err != nil {
+			t.Errorf("%s: KillTree: %v", tt.name, err)
+		}
+		for _, member := range members {
+			if Alive(member.PID) {
+				t.Errorf("%s: process %d (%s) survived", tt.name, member.PID, member.Comm)
+			}
+		}
+	}
+}
+
+func TestSamplerTree(t *testing.T) {
+	cmd := startTree(t, "sleep 30 & sleep 30 & wait")
+	defer KillTree(cmd.Process.Pid, 0)
+
+	var sampler Sampler
+	roots, usage, err := sampler.Tree(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("Tree: %v", err)
+	}
+	if len(roots) != 1 || roots[0].PID != cmd.Process.Pid {
+		t.Fatalf("roots = %+v, want the leader", roots)
+	}
+	if len(roots[0].Children) != 2 || roots[0].Children[0].Command != "sleep 30" {
+		t.Errorf("children = %+v", roots[0].Children)
+	}
+	if usage.Processes != 3 || usage.RSS <= 0 {
+		t.Errorf("usage = %+v", usage)
+	}
+	if roots[0].Runtime < 0 || roots[0].Runtime > 60 {
+		t.Errorf("runtime = %v", roots[0].Runtime)
+	}
+}
+
+func TestStartedAt(t *testing.T) {
+	before := time.Now()
+	cmd := startTree(t, "sleep 30 & sleep 30 & wait")
+	defer KillTree(cmd.Process.Pid, 0)
+
+	started, err := StartedAt(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("StartedAt: %v", err)
+	}
+	// Start times are in clock ticks since a boot time in whole seconds
+	if d := started.Sub(before); d < -2*time.Second || d > 2*time.Second {
+		t.Errorf("started %v after the test began", d)
+	}
+
+	KillTree(cmd.Process.Pid, 0)
+	if _, err := StartedAt(cmd.Process.Pid); err == nil {
+		t.Error("StartedAt succeeded for a process that has gone")
+	}
+}
+
+func TestPorts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	found := ports(os.Getpid(), listeningSockets())
+	if len(found) != 1 || found[0] != port {
+		t.Errorf("ports = %v, want [%d]", found, port)
+	}
+}
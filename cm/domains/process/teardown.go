@@ -0,0 +1,108 @@
+package process
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// DefaultGrace is how long processes get to exit after SIGTERM before
+// they are killed
+var DefaultGrace = 5 * time.Second
+
+// pollInterval is how often teardown checks for surviving processes
+const pollInterval = 50 * time.Millisecond
+
+// killWait is how long to wait for processes to die after SIGKILL
+const killWait = 2 * time.Second
+
+// KillTree terminates the session led by leader and everything it
+// started. SIGTERM goes to the leader's process group and to every member
+// process; anything still running after grace gets SIGKILL. It returns an
+// error naming any processes that survive.
+func KillTree(leader int, grace time.Duration) error {
+	if leader <= 0 {
+		return nil
+	}
+
+	// Remember every member up front: once the leader exits its children
+	// are reparented and can only be found by these PIDs
+	targets := make(map[int]Stat)
+	collect := func() {
+		members, _ := Members(leader)
+		for _, member := range members {
+			targets[member.PID] = member
+		}
+	}
+	collect()
+
+	signalAll(leader, targets, syscall.SIGTERM)
+	// Stopped processes only act on SIGTERM once continued
+	signalAll(leader, targets, syscall.SIGCONT)
+
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		collect()
+		if len(survivors(targets)) == 0 {
+			return nil
+		}
+		time.Sleep(pollInterval)
+	}
+
+	collect()
+	signalAll(leader, targets, syscall.SIGKILL)
+
+	deadline = time.Now().Add(killWait)
+	for {
+		remaining := survivors(targets)
+		if len(remaining) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("processes survived SIGKILL: %v", remaining)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// signalAll sends sig to the leader's process group and to each target
+func signalAll(leader int, targets map[int]Stat, sig syscall.Signal) {
+	syscall.Kill(-leader, sig)
+	for pid := range targets {
+		syscall.Kill(pid, sig)
+	}
+}
+
+// survivors returns the targets still running. A PID that now belongs to
+// a different process, started after the target, is not a survivor.
+func survivors(targets map[int]Stat) []int {
+	var alive []int
+	for pid, target := range targets {
+		stat, err := ReadStat(pid)
+		if err != nil || stat.Exited() || stat.StartTime != target.StartTime {
+			delete(targets, pid)
+			continue
+		}
+		alive = append(alive, pid)
+	}
+	return alive
+}
+
+// WaitExited blocks until a child process has exited, without reaping it.
+// Until it is reaped its PID cannot be reused, so its session can still be
+// torn down safely.
+func WaitExited(pid int) error {
+	const pPID = 1     // P_PID from waitid(2)
+	var info [128]byte // siginfo_t
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid), uintptr(unsafe.Pointer(&info[0])), syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
@@ -0,0 +1,201 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks is the unit of the CPU times in /proc/<pid>/stat. Linux
+// reports them in USER_HZ, which is 100 on every supported architecture.
+const clockTicks = 100
+
+// minSampleInterval is the shortest interval CPU usage is measured over.
+// Samples taken closer together reuse the previous figure.
+const minSampleInterval = 500 * time.Millisecond
+
+// Process describes one process in a session's tree
+type Process struct {
+	PID        int        `json:"pid"`
+	PPID       int        `json:"ppid"`
+	Name       string     `json:"name"`
+	Command    string     `json:"command"`
+	State      string     `json:"state"`
+	CPUPercent float64    `json:"cpu_percent"` // of one core, so may exceed 100
+	RSS        int64      `json:"rss"`         // bytes
+	Ports      []int      `json:"ports,omitempty"`
+	Started    time.Time  `json:"started"`
+	Runtime    float64    `json:"runtime"` // seconds
+	Children   []*Process `json:"children,omitempty"`
+}
+
+// Usage sums the resources used by a session's processes
+type Usage struct {
+	CPUPercent float64 `json:"cpu_percent"`
+	RSS        int64   `json:"rss"` // bytes
+	Processes  int     `json:"processes"`
+}
+
+// sample is a process's CPU time when it was last measured
+type sample struct {
+	startTime uint64
+	ticks     uint64
+	at        time.Time
+	percent   float64
+}
+
+// Sampler measures the CPU usage of a session's processes between calls.
+// The zero value is ready to use.
+type Sampler struct {
+	mu   sync.Mutex
+	prev map[int]sample
+}
+
+// Tree returns the processes of the session led by leader, arranged by
+// parent, along with their total usage. The first time a process is seen
+// its CPU usage is averaged over its lifetime, as ps does.
+func (s *Sampler) Tree(leader int) ([]*Process, Usage, error) {
+	members, err := Members(leader)
+	if err != nil {
+		return nil, Usage{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	pageSize := int64(os.Getpagesize())
+	sockets := listeningSockets()
+
+	var usage Usage
+	next := make(map[int]sample, len(members))
+	byPID := make(map[int]*Process, len(members))
+	for _, stat := range members {
+		started := startTime(stat)
+		proc := &Process{
+			PID:     stat.PID,
+			PPID:    stat.PPID,
+			Name:    stat.Comm,
+			Command: cmdline(stat.PID),
+			State:   string(stat.State),
+			RSS:     stat.RSS * pageSize,
+			Ports:   ports(stat.PID, sockets),
+			Started: started,
+			Runtime: now.Sub(started).Seconds(),
+		}
+		if proc.Command == "" {
+			proc.Command = "[" + stat.Comm + "]"
+		}
+
+		current := sample{startTime: stat.StartTime, ticks: stat.UTime + stat.STime, at: now}
+		prev, seen := s.prev[stat.PID]
+		switch {
+		case seen && prev.startTime == current.startTime && now.Sub(prev.at) < minSampleInterval:
+			current = prev
+		case seen && prev.startTime == current.startTime:
+			current.percent = cpuPercent(current.ticks-prev.ticks, now.Sub(prev.at))
+		case proc.Runtime > 0:
+			current.percent = cpuPercent(current.ticks, now.Sub(started))
+		}
+		proc.CPUPercent = current.percent
+		next[stat.PID] = current
+
+		usage.CPUPercent += proc.CPUPercent
+		usage.RSS += proc.RSS
+		usage.Processes++
+		byPID[stat.PID] = proc
+	}
+	s.prev = next
+
+	// Processes whose parent is not in the session, such as the leader or
+	// orphans reparented to init, are roots
+	var roots []*Process
+	for _, stat := range members {
+		proc := byPID[stat.PID]
+		if parent, ok := byPID[proc.PPID]; ok && proc.PID != leader {
+			parent.Children = append(parent.Children, proc)
+		} else {
+			roots = append(roots, proc)
+		}
+	}
+	sortTree(roots)
+	return roots, usage, nil
+}
+
+// cpuPercent converts CPU ticks used over an interval to a percentage of
+// one core
+func cpuPercent(ticks uint64, interval time.Duration) float64 {
+	if interval <= 0 {
+		return 0
+	}
+	return float64(ticks) / clockTicks / interval.Seconds() * 100
+}
+
+// sortTree orders processes by PID at every level
+func sortTree(procs []*Process) {
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	for _, proc := range procs {
+		sortTree(proc.Children)
+	}
+}
+
+// cmdline returns a process's command line with its arguments separated
+// by spaces, or "" for kernel threads and processes that have exited
+func cmdline(pid int) string {
+	data, err := os.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return ""
+	}
+	return strings.Join(strings.Split(strings.TrimRight(string(data), "\x00"), "\x00"), " ")
+}
+
+// StartedAt returns when a live process started. Compared with when a
+// process was known to start, it tells whether a PID still belongs to it.
+func StartedAt(pid int) (time.Time, error) {
+	stat, err := ReadStat(pid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if stat.Exited() {
+		return time.Time{}, fmt.Errorf("process %d has exited", pid)
+	}
+	return startTime(stat), nil
+}
+
+// startTime returns when a process started
+func startTime(stat Stat) time.Time {
+	return bootTime().Add(time.Duration(stat.StartTime) * time.Second / clockTicks)
+}
+
+var (
+	bootOnce sync.Once
+	booted   time.Time
+)
+
+// bootTime returns when the system booted, from the btime line of
+// /proc/stat
+func bootTime() time.Time {
+	bootOnce.Do(func() {
+		file, err := os.Open(filepath.Join(procDir, "stat"))
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rest, ok := strings.CutPrefix(scanner.Text(), "btime "); ok {
+				if secs, err := strconv.ParseInt(strings.TrimSpace(rest), 10, 64); err == nil {
+					booted = time.Unix(secs, 0)
+				}
+				return
+			}
+		}
+	})
+	return booted
+}
@@ -0,0 +1,55 @@
+package profile
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+// IsClaude reports whether the profile runs Claude Code
+func (p *Profile) IsClaude() bool {
+	return filepath.Base(p.Command) == "claude"
+}
+
+// CommandLine returns the arguments to start the profile's command with,
+// including any Claude options. Options are rejected for other agents.
+func (p *Profile) CommandLine(opts session.ClaudeOptions) ([]string, error) {
+	args := append([]string(nil), p.Args...)
+	if opts.IsZero() {
+		return args, nil
+	}
+	if !p.IsClaude() {
+		return nil, fmt.Errorf("profile %s does not run Claude, so Claude options cannot be used", p.Name)
+	}
+	return append(args, ClaudeArgs(opts)...), nil
+}
+
+// ClaudeArgs translates options to claude's command line. The list flags
+// take every argument up to the next flag, so the prompt comes last, after
+// "--".
+func ClaudeArgs(opts session.ClaudeOptions) []string {
+	var args []string
+	if opts.Model != "" {
+		args = append(args, "--model", opts.Model)
+	}
+	if opts.PermissionMode != "" {
+		args = append(args, "--permission-mode", opts.PermissionMode)
+	}
+	if len(opts.AllowedTools) > 0 {
+		args = append(append(args, "--allowed-tools"), opts.AllowedTools...)
+	}
+	if len(opts.DisallowedTools) > 0 {
+		args = append(append(args, "--disallowed-tools"), opts.DisallowedTools...)
+	}
+	if len(opts.AddDirs) > 0 {
+		args = append(append(args, "--add-dir"), opts.AddDirs...)
+	}
+	if opts.MCPConfig != "" {
+		args = append(args, "--mcp-config", opts.MCPConfig)
+	}
+	if opts.Prompt != "" {
+		args = append(args, "--", opts.Prompt)
+	}
+	return args
+}
@@ -0,0 +1,90 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+func TestCommandLine(t *testing.T) {
+	dir := t.TempDir()
+	claude := &Profile{Name: Claude, Command: "/usr/local/bin/claude", Args: []string{"--verbose"}}
+	opts := session.ClaudeOptions{
+		Prompt:          "-fix the tests",
+		Model:           "sonnet",
+		PermissionMode:  "acceptEdits",
+		AllowedTools:    []string{"Bash(git *)", "Edit"},
+		DisallowedTools: []string{"WebFetch"},
+		AddDirs:         []string{dir},
+		MCPConfig:       `{"mcpServers": {}}`,
+	}
+
+	got, err := claude.CommandLine(opts)
+	if err != nil {
+		t.Fatalf("CommandLine: %v", err)
+	}
+	want := []string{
+		"--verbose",
+		"--model", "sonnet",
+		"--permission-mode", "acceptEdits",
+		"--allowed-tools", "Bash(git *)", "Edit",
+		"--disallowed-tools", "WebFetch",
+		"--add-dir", dir,
+		"--mcp-config", `{"mcpServers": {}}`,
+		"--", "-fix the tests",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandLine = %q, want %q", got, want)
+	}
+
+	shell := &Profile{Name: Shell, Command: "bash", Args: []string{"-i"}}
+	if got, err := shell.CommandLine(session.ClaudeOptions{}); err != nil || !reflect.DeepEqual(got, []string{"-i"}) {
+		t.Errorf("shell without options = %q, %v", got, err)
+	}
+	if _, err := shell.CommandLine(session.ClaudeOptions{Model: "opus"}); err == nil {
+		t.Error("Claude options should be rejected for a shell")
+	}
+}
+
+func TestValidateClaudeOptions(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "mcp.json")
+	os.WriteFile(config, []byte("{}"), 0644)
+
+	valid := []session.ClaudeOptions{
+		{},
+		{Model: "claude-sonnet-4-5-20250929", PermissionMode: "plan"},
+		{Model: "sonnet[1m]"},
+		{AddDirs: []string{dir}, MCPConfig: config},
+		{MCPConfig: ` {"mcpServers": {}}`},
+	}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Validate(%+v): %v", opts, err)
+		}
+	}
+
+	invalid := []session.ClaudeOptions{
+		{Prompt: strings.Repeat("x", 65*1024)},
+		{Model: "--dangerously-skip-permissions"},
+		{Model: "opus sonnet"},
+		{PermissionMode: "yolo"},
+		{AllowedTools: []string{""}},
+		{DisallowedTools: []string{"--model"}},
+		{AddDirs: []string{"relative/dir"}},
+		{AddDirs: []string{filepath.Join(dir, "missing")}},
+		{AddDirs: []string{config}},
+		{MCPConfig: "{not json"},
+		{MCPConfig: "mcp.json"},
+		{MCPConfig: dir},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", opts)
+		}
+	}
+}
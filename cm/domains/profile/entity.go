@@ -0,0 +1,103 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+)
+
+// DefaultTerm is the TERM given to sessions whose profile sets none
+const DefaultTerm = "xterm-256color"
+
+// Profile describes how to launch an agent in a session and how to tell
+// what it is doing
+type Profile struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Command     string            `json:"command"`
+	Args        []string          `json:"args,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`  // added to the server's environment
+	Term        string            `json:"term,omitempty"` // TERM, which Env cannot override
+
+	// ReadyPattern matches the screen once the agent has started. Without
+	// one, the agent is ready when it first stops working.
+	ReadyPattern string `json:"readyPattern,omitempty"`
+	// PromptPatterns match questions that block until the user answers, and
+	// BusyPatterns indicators shown while the agent works. Unset lists keep
+	// the detector's defaults.
+	PromptPatterns []string `json:"promptPatterns,omitempty"`
+	BusyPatterns   []string `json:"busyPatterns,omitempty"`
+
+	// Available reports whether Command can be found, as of the last listing
+	Available bool `json:"available"`
+
+	ready  *regexp.Regexp
+	prompt []*regexp.Regexp
+	busy   []*regexp.Regexp
+}
+
+// Compile validates the profile and its patterns
+func (p *Profile) Compile() error {
+	if p.Name == "" {
+		return errors.New("profile has no name")
+	}
+	if p.Command == "" {
+		return fmt.Errorf("profile %s: no command", p.Name)
+	}
+	if p.Term == "" {
+		p.Term = DefaultTerm
+	}
+
+	var err error
+	if p.ReadyPattern != "" {
+		if p.ready, err = regexp.Compile(p.ReadyPattern); err != nil {
+			return fmt.Errorf("profile %s: invalid ready pattern: %v", p.Name, err)
+		}
+	}
+	if p.prompt, err = compileAll(p.PromptPatterns); err != nil {
+		return fmt.Errorf("profile %s: invalid prompt pattern: %v", p.Name, err)
+	}
+	if p.busy, err = compileAll(p.BusyPatterns); err != nil {
+		return fmt.Errorf("profile %s: invalid busy pattern: %v", p.Name, err)
+	}
+	return nil
+}
+
+// Ready returns the compiled ReadyPattern, or nil
+func (p *Profile) Ready() *regexp.Regexp { return p.ready }
+
+// Prompts returns the compiled PromptPatterns, or nil for the defaults
+func (p *Profile) Prompts() []*regexp.Regexp { return p.prompt }
+
+// Busy returns the compiled BusyPatterns, or nil for the defaults
+func (p *Profile) Busy() []*regexp.Regexp { return p.busy }
+
+// Resolve looks up the profile's command on the PATH
+func (p *Profile) Resolve() (string, error) {
+	return exec.LookPath(p.Command)
+}
+
+// Environment returns the variables the profile adds to the server's own,
+// with TERM last so that it wins
+func (p *Profile) Environment() []string {
+	env := make([]string, 0, len(p.Env)+1)
+	for key, value := range p.Env {
+		if key != "TERM" {
+			env = append(env, key+"="+value)
+		}
+	}
+	return append(env, "TERM="+p.Term)
+}
+
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
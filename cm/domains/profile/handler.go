@@ -0,0 +1,29 @@
+package profile
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler handles HTTP requests for agent profiles
+type Handler struct {
+	profileManager *Manager
+}
+
+// NewHandler creates a new profile handler
+func NewHandler(profileManager *Manager) *Handler {
+	return &Handler{
+		profileManager: profileManager,
+	}
+}
+
+// HandleProfiles handles GET /api/profiles
+func (h *Handler) HandleProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.profileManager.List())
+}
@@ -0,0 +1,141 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Names of the built-in profiles
+const (
+	Claude = "claude"
+	Shell  = "shell"
+)
+
+// ErrNotFound is returned for an unknown profile name
+var ErrNotFound = errors.New("profile not found")
+
+// ErrUnavailable is returned when a profile's command cannot be found
+var ErrUnavailable = errors.New("profile command not found")
+
+// builtins are always defined, though a profiles file may replace them
+func builtins() []Profile {
+	return []Profile{
+		{
+			Name:        Claude,
+			Description: "Claude Code",
+			Command:     "claude",
+		},
+		{
+			Name:         Shell,
+			Description:  "Interactive bash shell",
+			Command:      "bash",
+			Args:         []string{"-i"},
+			ReadyPattern: `(?m)[$#]\s*$`,
+		},
+	}
+}
+
+// Manager holds the available agent profiles
+type Manager struct {
+	profiles map[string]*Profile
+	mu       sync.RWMutex
+}
+
+// NewManager creates a manager holding the built-in profiles
+func NewManager() *Manager {
+	m := &Manager{profiles: make(map[string]*Profile)}
+	for _, p := range builtins() {
+		p := p
+		if err := m.Add(&p); err != nil {
+			panic(err)
+		}
+	}
+	return m
+}
+
+// Load adds the profiles in a JSON file holding a list of profiles,
+// replacing any of the same name. A missing file is not an error.
+func (m *Manager) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var profiles []*Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for _, p := range profiles {
+		if err := p.Compile(); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	for _, p := range profiles {
+		m.Add(p)
+	}
+	return nil
+}
+
+// Add compiles and adds a profile, replacing any of the same name
+func (m *Manager) Add(p *Profile) error {
+	if err := p.Compile(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.profiles[p.Name] = p
+	return nil
+}
+
+// Get returns a profile by name
+func (m *Manager) Get(name string) (*Profile, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, exists := m.profiles[name]
+	return p, exists
+}
+
+// List returns every profile sorted by name, noting which can be launched
+func (m *Manager) List() []Profile {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	profiles := make([]Profile, 0, len(m.profiles))
+	for _, p := range m.profiles {
+		listed := *p
+		_, err := p.Resolve()
+		listed.Available = err == nil
+		profiles = append(profiles, listed)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles
+}
+
+// Select returns the named profile if its command can be found. With no
+// name it prefers Claude and falls back to a shell.
+func (m *Manager) Select(name string) (*Profile, error) {
+	if name == "" {
+		if p, ok := m.Get(Claude); ok {
+			if _, err := p.Resolve(); err == nil {
+				return p, nil
+			}
+		}
+		name = Shell
+	}
+
+	p, ok := m.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if _, err := p.Resolve(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnavailable, p.Command)
+	}
+	return p, nil
+}
@@ -0,0 +1,78 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeProfiles(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	m := NewManager()
+	path := writeProfiles(t, `[
+		{"name": "claude", "command": "claude", "args": ["--model", "opus"]},
+		{"name": "sh", "command": "sh", "env": {"PS1": "$ ", "TERM": "dumb"}, "term": "vt100",
+		 "readyPattern": "\\$ $", "promptPatterns": ["Apply\\?"], "busyPatterns": ["working"]}
+	]`)
+	if err := m.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if p, _ := m.Get(Claude); len(p.Args) != 2 || p.Term != DefaultTerm {
+		t.Errorf("claude = %+v, want the file's version", p)
+	}
+	sh, ok := m.Get("sh")
+	if !ok || sh.Ready() == nil || len(sh.Prompts()) != 1 || len(sh.Busy()) != 1 {
+		t.Fatalf("sh = %+v", sh)
+	}
+	env := sh.Environment()
+	if len(env) != 2 || env[0] != "PS1=$ " || env[1] != "TERM=vt100" {
+		t.Errorf("environment = %q", env)
+	}
+	if names := m.List(); len(names) != 3 || names[0].Name != Claude || names[1].Name != "sh" {
+		t.Errorf("List = %+v", names)
+	}
+
+	if err := m.Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+	for _, bad := range []string{
+		`{}`,
+		`[{"name": "x"}]`,
+		`[{"command": "x"}]`,
+		`[{"name": "x", "command": "x", "readyPattern": "("}]`,
+		`[{"name": "x", "command": "x", "busyPatterns": ["["]}]`,
+	} {
+		if err := m.Load(writeProfiles(t, bad)); err == nil {
+			t.Errorf("Load(%s) should fail", bad)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	m := NewManager()
+	m.Add(&Profile{Name: "missing", Command: "no-such-agent-command"})
+	m.Add(&Profile{Name: Claude, Command: "no-such-claude-command"})
+
+	if p, err := m.Select(""); err != nil || p.Name != Shell {
+		t.Errorf("default without claude = %v, %v", p, err)
+	}
+	if p, err := m.Select(Shell); err != nil || p.Name != Shell {
+		t.Errorf("shell = %v, %v", p, err)
+	}
+	if _, err := m.Select("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown profile: err = %v", err)
+	}
+	if _, err := m.Select("missing"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("missing command: err = %v", err)
+	}
+}
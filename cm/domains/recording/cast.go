@@ -0,0 +1,238 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLineSize bounds a single event line when reading a recording
+const maxLineSize = 4 * 1024 * 1024
+
+// tailSize is how much of the end of a recording is read at first to find
+// its last event
+const tailSize = 64 * 1024
+
+// Event is a single timed event from a recording
+type Event struct {
+	Time float64 // seconds since the start of the recording
+	Type string
+	Data string
+}
+
+// Cast is a parsed asciicast v2 recording
+type Cast struct {
+	Header Header
+	Events []Event
+}
+
+// Info describes a recording on disk
+type Info struct {
+	Name     string    `json:"name"`
+	Title    string    `json:"title,omitempty"`
+	Width    int       `json:"width"`
+	Height   int       `json:"height"`
+	Started  time.Time `json:"started"`
+	Duration float64   `json:"duration"`
+	Size     int64     `json:"size"`
+}
+
+// ReadFile parses an asciicast v2 file
+func ReadFile(path string) (*Cast, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	if !scanner.Scan() {
+		return nil, errors.New("recording is empty")
+	}
+	cast := &Cast{}
+	if err := parseHeader(scanner.Bytes(), &cast.Header); err != nil {
+		return nil, err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		event, err := parseEvent(line)
+		if err != nil {
+			// A recording cut short by a crash may end in a partial line
+			break
+		}
+		cast.Events = append(cast.Events, event)
+	}
+	return cast, scanner.Err()
+}
+
+// parseHeader decodes a recording's header line
+func parseHeader(line []byte, header *Header) error {
+	if err := json.Unmarshal(line, header); err != nil {
+		return fmt.Errorf("invalid recording header: %v", err)
+	}
+	if header.Version != 2 {
+		return fmt.Errorf("unsupported asciicast version %d", header.Version)
+	}
+	return nil
+}
+
+// readInfo reads a recording's header and the time of its last event,
+// without reading the events in between
+func readInfo(path string) (Header, float64, error) {
+	var header Header
+	file, err := os.Open(path)
+	if err != nil {
+		return header, 0, err
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(io.LimitReader(file, maxLineSize)).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return header, 0, errors.New("recording is empty")
+	}
+	if err := parseHeader(line, &header); err != nil {
+		return header, 0, err
+	}
+	events := int64(len(line))
+
+	stat, err := file.Stat()
+	if err != nil {
+		return header, 0, err
+	}
+	// Look back from the end for the last complete event, further each
+	// time one is not found
+	for size := int64(tailSize); ; size *= 2 {
+		from := max(stat.Size()-size, events)
+		tail := make([]byte, stat.Size()-from)
+		if _, err := file.ReadAt(tail, from); err != nil && err != io.EOF {
+			return header, 0, err
+		}
+		lines := bytes.Split(tail, []byte("\n"))
+		if from > events {
+			// The first line may have started before the tail
+			lines = lines[1:]
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if event, err := parseEvent(lines[i]); err == nil {
+				return header, event.Time, nil
+			}
+		}
+		if from == events || size >= maxLineSize {
+			return header, 0, nil
+		}
+	}
+}
+
+// parseEvent decodes a [time, type, data] event line
+func parseEvent(line []byte) (Event, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return Event{}, err
+	}
+	if len(raw) != 3 {
+		return Event{}, errors.New("malformed event")
+	}
+
+	var event Event
+	if err := json.Unmarshal(raw[0], &event.Time); err != nil {
+		return Event{}, err
+	}
+	if err := json.Unmarshal(raw[1], &event.Type); err != nil {
+		return Event{}, err
+	}
+	if err := json.Unmarshal(raw[2], &event.Data); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// Duration returns the time of the last event
+func (c *Cast) Duration() float64 {
+	if len(c.Events) == 0 {
+		return 0
+	}
+	return c.Events[len(c.Events)-1].Time
+}
+
+// ParseResize decodes the "COLSxROWS" data of a resize event
+func ParseResize(data string) (rows, cols uint16, ok bool) {
+	parts := strings.SplitN(data, "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	c, err1 := strconv.ParseUint(parts[0], 10, 16)
+	r, err2 := strconv.ParseUint(parts[1], 10, 16)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return uint16(r), uint16(c), true
+}
+
+// ResolvePath returns the path of a recording in dir, rejecting names that
+// would escape it
+func ResolvePath(dir, name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || !strings.HasSuffix(name, Extension) {
+		return "", errors.New("invalid recording name")
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// List returns the recordings in dir, newest first
+func List(dir string) ([]Info, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Info{}, nil
+		}
+		return nil, err
+	}
+
+	recordings := []Info{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), Extension) {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		header, duration, err := readInfo(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		recordings = append(recordings, Info{
+			Name:     entry.Name(),
+			Title:    header.Title,
+			Width:    header.Width,
+			Height:   header.Height,
+			Started:  time.Unix(header.Timestamp, 0),
+			Duration: duration,
+			Size:     stat.Size(),
+		})
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].Started.After(recordings[j].Started)
+	})
+	return recordings, nil
+}
@@ -0,0 +1,49 @@
+package recording
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListReadsDurationFromTail(t *testing.T) {
+	dir := t.TempDir()
+	header := `{"version":2,"width":80,"height":24,"timestamp":1700000000,"title":"demo"}` + "\n"
+
+	var long strings.Builder
+	long.WriteString(header)
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintf(&long, "[%d.5, \"o\", %q]\n", i, strings.Repeat("x", 100))
+	}
+	long.WriteString(`[5001.5, "o", "cut sh`) // a crash left a partial line
+
+	recordings := map[string]struct {
+		content  string
+		duration float64
+	}{
+		"long.cast":    {long.String(), 5000.5},
+		"short.cast":   {header + `[0.25, "o", "hi"]` + "\n", 0.25},
+		"silent.cast":  {header, 0},
+		"invalid.cast": {"not a recording\n", 0},
+	}
+	for name, r := range recordings {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(r.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := List(dir)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("listed %d recordings, want 3: %+v", len(list), list)
+	}
+	for _, info := range list {
+		if want := recordings[info.Name].duration; info.Duration != want || info.Title != "demo" || info.Width != 80 {
+			t.Errorf("%s: %+v, want duration %v", info.Name, info, want)
+		}
+	}
+}
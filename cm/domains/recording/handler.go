@@ -0,0 +1,126 @@
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/user/claude-manager/domains/terminal"
+)
+
+// Handler handles HTTP requests for recordings
+type Handler struct {
+	upgrader *websocket.Upgrader
+}
+
+// NewHandler creates a new recording handler
+func NewHandler(upgrader *websocket.Upgrader) *Handler {
+	return &Handler{
+		upgrader: upgrader,
+	}
+}
+
+// HandleRecordings handles GET /api/recordings
+func (h *Handler) HandleRecordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	recordings, err := List(DefaultDir)
+	if err != nil {
+		log.Printf("Failed to list recordings: %v", err)
+		http.Error(w, "Failed to list recordings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recordings)
+}
+
+// HandlePlayback handles /ws/playback/{recording}. Output is streamed as
+// binary frames in the same format as a live session; the client controls
+// playback with play, pause, speed and seek messages.
+func (h *Handler) HandlePlayback(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[len("/ws/playback/"):]
+	path, err := ResolvePath(DefaultDir, name)
+	if err != nil {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	cast, err := ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read recording %s: %v", name, err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	speed, _ := strconv.ParseFloat(query.Get("speed"), 64)
+	idleLimit := DefaultIdleLimit
+	if idle := query.Get("idle"); idle != "" {
+		idleLimit, _ = strconv.ParseFloat(idle, 64)
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	player := NewPlayer(cast, speed, idleLimit)
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Read control messages; the player goroutine does all the writing
+	go func() {
+		defer cancel()
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var cmd Command
+			if err := json.Unmarshal(message, &cmd); err == nil {
+				player.Control(cmd)
+			}
+		}
+	}()
+
+	sink := &socketSink{conn: conn}
+	if err := sink.Resize(uint16(cast.Header.Height), uint16(cast.Header.Width)); err != nil {
+		return
+	}
+	player.Run(ctx, sink)
+}
+
+// socketSink writes playback to a WebSocket client
+type socketSink struct {
+	conn   *websocket.Conn
+	offset int64
+}
+
+func (s *socketSink) Output(data []byte) error {
+	frame := terminal.NewOutputFrame(s.offset, data)
+	s.offset += int64(len(data))
+	return s.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+func (s *socketSink) Resize(rows, cols uint16) error {
+	return s.conn.WriteJSON(terminal.ControlMessage{Type: terminal.MessageResize, Rows: rows, Cols: cols})
+}
+
+func (s *socketSink) Reset() error {
+	s.offset = 0
+	return s.conn.WriteJSON(map[string]string{"type": "reset"})
+}
+
+func (s *socketSink) Status(status Status) error {
+	return s.conn.WriteJSON(status)
+}
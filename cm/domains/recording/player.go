@@ -0,0 +1,250 @@
+package recording
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Playback speed limits
+const (
+	MinSpeed = 0.5
+	MaxSpeed = 8.0
+)
+
+// DefaultIdleLimit caps pauses in playback, in seconds
+const DefaultIdleLimit = 2.0
+
+// statusInterval throttles position updates sent while playing
+const statusInterval = 250 * time.Millisecond
+
+// Playback control message types
+const (
+	CommandPlay  = "play"
+	CommandPause = "pause"
+	CommandSpeed = "speed"
+	CommandSeek  = "seek"
+)
+
+// Command controls a running player
+type Command struct {
+	Type  string  `json:"type"`
+	Speed float64 `json:"speed,omitempty"`
+	Time  float64 `json:"time,omitempty"`
+}
+
+// Status reports the player's state to the client
+type Status struct {
+	Type     string  `json:"type"`
+	Position float64 `json:"position"`
+	Duration float64 `json:"duration"`
+	Speed    float64 `json:"speed"`
+	Paused   bool    `json:"paused"`
+	Finished bool    `json:"finished"`
+}
+
+// Sink receives what the player plays back
+type Sink interface {
+	Output(data []byte) error
+	Resize(rows, cols uint16) error
+	Reset() error
+	Status(status Status) error
+}
+
+// Player replays a recording in real time, scaled by speed, with long idle
+// gaps shortened to the idle limit
+type Player struct {
+	events   []Event // output and resize events on the compressed timeline
+	duration float64
+	speed    float64
+	paused   bool
+	position float64 // current time on the compressed timeline
+	next     int     // index of the next event to play
+
+	commands chan Command
+}
+
+// NewPlayer prepares a recording for playback. Gaps between events longer
+// than idleLimit seconds are shortened to idleLimit; zero disables this.
+func NewPlayer(cast *Cast, speed, idleLimit float64) *Player {
+	p := &Player{
+		speed:    clampSpeed(speed),
+		commands: make(chan Command, 16),
+	}
+
+	var last, shift float64
+	for _, event := range cast.Events {
+		if event.Type != EventOutput && event.Type != EventResize {
+			continue
+		}
+		if gap := event.Time - last; idleLimit > 0 && gap > idleLimit {
+			shift += gap - idleLimit
+		}
+		last = event.Time
+		event.Time -= shift
+		p.events = append(p.events, event)
+	}
+	if len(p.events) > 0 {
+		p.duration = p.events[len(p.events)-1].Time
+	}
+	return p
+}
+
+// Control queues a command for the player
+func (p *Player) Control(cmd Command) {
+	select {
+	case p.commands <- cmd:
+	default:
+	}
+}
+
+// Run plays the recording into sink until ctx is cancelled. Reaching the
+// end does not return, so the client can still seek backwards.
+func (p *Player) Run(ctx context.Context, sink Sink) error {
+	if err := sink.Status(p.status()); err != nil {
+		return err
+	}
+
+	lastStatus := time.Now()
+	for {
+		var timer <-chan time.Time
+		started := time.Now()
+		if !p.paused && p.next < len(p.events) {
+			wait := time.Duration((p.events[p.next].Time - p.position) / p.speed * float64(time.Second))
+			timer = time.After(wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-timer:
+			event := p.events[p.next]
+			p.position = event.Time
+			p.next++
+			if err := p.play(sink, event); err != nil {
+				return err
+			}
+			if p.next == len(p.events) || time.Since(lastStatus) > statusInterval {
+				lastStatus = time.Now()
+				if err := sink.Status(p.status()); err != nil {
+					return err
+				}
+			}
+
+		case cmd := <-p.commands:
+			// Account for the time spent waiting before the command arrived
+			if timer != nil {
+				p.position += time.Since(started).Seconds() * p.speed
+				if p.position > p.events[p.next].Time {
+					p.position = p.events[p.next].Time
+				}
+			}
+			if err := p.apply(sink, cmd); err != nil {
+				return err
+			}
+			if err := sink.Status(p.status()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// apply handles a control command
+func (p *Player) apply(sink Sink, cmd Command) error {
+	switch cmd.Type {
+	case CommandPlay:
+		if p.next == len(p.events) {
+			return p.seek(sink, 0)
+		}
+		p.paused = false
+	case CommandPause:
+		p.paused = true
+	case CommandSpeed:
+		p.speed = clampSpeed(cmd.Speed)
+	case CommandSeek:
+		return p.seek(sink, cmd.Time)
+	}
+	return nil
+}
+
+// seek jumps to t by resetting the client and replaying everything before
+// it at once
+func (p *Player) seek(sink Sink, t float64) error {
+	if t < 0 {
+		t = 0
+	}
+	if t > p.duration {
+		t = p.duration
+	}
+
+	if err := sink.Reset(); err != nil {
+		return err
+	}
+
+	var output strings.Builder
+	next := 0
+	for ; next < len(p.events) && p.events[next].Time <= t; next++ {
+		event := p.events[next]
+		if event.Type == EventResize {
+			// Flush so output lands at the size it was written for
+			if output.Len() > 0 {
+				if err := sink.Output([]byte(output.String())); err != nil {
+					return err
+				}
+				output.Reset()
+			}
+			if err := p.play(sink, event); err != nil {
+				return err
+			}
+			continue
+		}
+		output.WriteString(event.Data)
+	}
+	if output.Len() > 0 {
+		if err := sink.Output([]byte(output.String())); err != nil {
+			return err
+		}
+	}
+
+	p.next = next
+	p.position = t
+	return nil
+}
+
+// play sends a single event to the sink
+func (p *Player) play(sink Sink, event Event) error {
+	if event.Type == EventResize {
+		if rows, cols, ok := ParseResize(event.Data); ok {
+			return sink.Resize(rows, cols)
+		}
+		return nil
+	}
+	return sink.Output([]byte(event.Data))
+}
+
+// status returns the player's current state
+func (p *Player) status() Status {
+	return Status{
+		Type:     "playback",
+		Position: p.position,
+		Duration: p.duration,
+		Speed:    p.speed,
+		Paused:   p.paused,
+		Finished: p.next == len(p.events),
+	}
+}
+
+// clampSpeed keeps speed within the supported range
+func clampSpeed(speed float64) float64 {
+	if speed == 0 {
+		return 1
+	}
+	if speed < MinSpeed {
+		return MinSpeed
+	}
+	if speed > MaxSpeed {
+		return MaxSpeed
+	}
+	return speed
+}
@@ -0,0 +1,90 @@
+package recording
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// recordingSink collects what a player sends
+type recordingSink struct {
+	output   string
+	resets   int
+	statuses []Status
+	finished func()
+}
+
+func (s *recordingSink) Output(data []byte) error       { s.output += string(data); return nil }
+func (s *recordingSink) Resize(rows, cols uint16) error { return nil }
+func (s *recordingSink) Reset() error                   { s.resets++; s.output = ""; return nil }
+func (s *recordingSink) Status(status Status) error {
+	s.statuses = append(s.statuses, status)
+	if status.Finished && s.finished != nil {
+		s.finished()
+	}
+	return nil
+}
+
+func testCast() *Cast {
+	return &Cast{
+		Header: Header{Version: 2, Width: 80, Height: 24},
+		Events: []Event{
+			{Time: 0.1, Type: EventOutput, Data: "a"},
+			{Time: 0.2, Type: EventInput, Data: "x"},
+			{Time: 60.2, Type: EventOutput, Data: "b"},
+			{Time: 60.3, Type: EventResize, Data: "100x30"},
+			{Time: 60.4, Type: EventOutput, Data: "c"},
+		},
+	}
+}
+
+func TestPlayerCompressesIdleGaps(t *testing.T) {
+	player := NewPlayer(testCast(), 1, 2)
+
+	// The minute of silence is shortened to the two second idle limit and
+	// input events are dropped
+	if len(player.events) != 4 {
+		t.Fatalf("got %d events, want 4", len(player.events))
+	}
+	if got := player.duration; got < 2.29 || got > 2.31 {
+		t.Errorf("duration = %v, want 2.3", got)
+	}
+}
+
+func TestPlayerSeek(t *testing.T) {
+	player := NewPlayer(testCast(), 1, 2)
+	sink := &recordingSink{}
+
+	if err := player.seek(sink, 2.15); err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+	if sink.resets != 1 || sink.output != "ab" {
+		t.Errorf("after seek output = %q with %d resets, want %q", sink.output, sink.resets, "ab")
+	}
+	if player.next != 2 {
+		t.Errorf("next event = %d, want 2", player.next)
+	}
+}
+
+func TestPlayerRunsToEnd(t *testing.T) {
+	player := NewPlayer(testCast(), MaxSpeed, 0.1)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	sink := &recordingSink{finished: cancel}
+	player.Run(ctx, sink)
+
+	if sink.output != "abc" {
+		t.Errorf("output = %q, want %q", sink.output, "abc")
+	}
+}
+
+func TestParseResize(t *testing.T) {
+	rows, cols, ok := ParseResize("120x40")
+	if !ok || rows != 40 || cols != 120 {
+		t.Errorf("ParseResize = %d, %d, %v", rows, cols, ok)
+	}
+	if _, _, ok := ParseResize("bogus"); ok {
+		t.Error("ParseResize accepted malformed data")
+	}
+}
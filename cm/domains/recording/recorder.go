@@ -0,0 +1,189 @@
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Extension is the file extension used for asciicast recordings
+const Extension = ".cast"
+
+// DefaultDir is where recordings are written. It is set from the
+// -recordings flag at startup.
+var DefaultDir = "recordings"
+
+// Event types defined by asciicast v2
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+// Header is the first line of an asciicast v2 file
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// maxPending bounds the events queued for the file. Beyond it, events are
+// dropped rather than letting a stalled disk use up memory.
+const maxPending = 16 << 20
+
+// Recorder writes a session's terminal traffic to an asciicast v2 file.
+// Events are queued and written by a goroutine of its own, so recording
+// never waits on the disk. It is safe for concurrent use.
+type Recorder struct {
+	Path         string
+	CaptureInput bool
+
+	file    *os.File
+	started time.Time
+	err     error // the first write error, reported by Close
+
+	mu      sync.Mutex
+	pending []byte // events not yet written
+	closed  bool
+	wake    chan struct{} // signals the writer that events are pending
+	done    chan struct{} // closed once the writer has written everything
+}
+
+// NewRecorder creates a recording file for a session in dir, named after the
+// session and the start time, and writes its header
+func NewRecorder(dir, sessionID, title string, rows, cols uint16, captureInput bool) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recordings directory: %v", err)
+	}
+
+	started := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s%s", sessionID, started.Format("20060102-150405"), Extension))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %v", err)
+	}
+
+	header := Header{
+		Version:   2,
+		Width:     int(cols),
+		Height:    int(rows),
+		Timestamp: started.Unix(),
+		Title:     title,
+		Env: map[string]string{
+			"TERM":  "xterm-256color",
+			"SHELL": os.Getenv("SHELL"),
+		},
+	}
+	line, err := json.Marshal(header)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write recording header: %v", err)
+	}
+
+	r := &Recorder{
+		Path:         path,
+		CaptureInput: captureInput,
+		file:         file,
+		started:      started,
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	go r.write()
+	return r, nil
+}
+
+// write writes queued events to the file until the recorder is closed
+func (r *Recorder) write() {
+	defer close(r.done)
+	flush := func() {
+		r.mu.Lock()
+		data := r.pending
+		r.pending = nil
+		r.mu.Unlock()
+		if len(data) == 0 {
+			return
+		}
+		if _, err := r.file.Write(data); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	for range r.wake {
+		flush()
+	}
+	flush()
+}
+
+// RecordOutput appends an output event
+func (r *Recorder) RecordOutput(data []byte) {
+	r.writeEvent(EventOutput, string(data))
+}
+
+// RecordInput appends an input event if input capture is enabled
+func (r *Recorder) RecordInput(data []byte) {
+	if r.CaptureInput {
+		r.writeEvent(EventInput, string(data))
+	}
+}
+
+// RecordResize appends a resize event
+func (r *Recorder) RecordResize(rows, cols uint16) {
+	r.writeEvent(EventResize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// writeEvent queues a [time, type, data] event line
+func (r *Recorder) writeEvent(eventType, data string) {
+	elapsed := time.Since(r.started).Seconds()
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	line := make([]byte, 0, len(payload)+32)
+	line = append(line, '[')
+	line = strconv.AppendFloat(line, elapsed, 'f', 6, 64)
+	line = append(line, `, "`...)
+	line = append(line, eventType...)
+	line = append(line, `", `...)
+	line = append(line, payload...)
+	line = append(line, "]\n"...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || len(r.pending)+len(line) > maxPending {
+		return
+	}
+	r.pending = append(r.pending, line...)
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close finishes the recording once the queued events are written
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.wake)
+	r.mu.Unlock()
+
+	<-r.done
+	if err := r.file.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
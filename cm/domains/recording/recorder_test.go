@@ -0,0 +1,58 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestRecorderWritesAsciicast(t *testing.T) {
+	recorder, err := NewRecorder(t.TempDir(), "session_1", "demo", 24, 80, false)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+
+	recorder.RecordOutput([]byte("hello \xe2\x94\x80\r\n"))
+	recorder.RecordInput([]byte("ls\r"))
+	recorder.RecordResize(40, 120)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	file, err := os.Open(recorder.Path)
+	if err != nil {
+		t.Fatalf("open recording: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+	var header Header
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	if header.Version != 2 || header.Width != 80 || header.Height != 24 || header.Title != "demo" {
+		t.Errorf("header = %+v", header)
+	}
+
+	var events [][]interface{}
+	for scanner.Scan() {
+		var event []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+
+	// Input is skipped because capture was not requested
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %v", len(events), events)
+	}
+	if events[0][1] != EventOutput || events[0][2] != "hello ─\r\n" {
+		t.Errorf("output event = %v", events[0])
+	}
+	if events[1][1] != EventResize || events[1][2] != "120x40" {
+		t.Errorf("resize event = %v", events[1])
+	}
+}
@@ -0,0 +1,563 @@
+package screen
+
+import (
+	"unicode/utf8"
+)
+
+// Parser states, following the DEC ANSI parser model
+const (
+	stateGround = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSI
+	stateString       // OSC, DCS, APC, PM and SOS payloads, which are ignored
+	stateStringEscape // ESC seen inside a string, expecting the ST terminator
+)
+
+// maxParams bounds the number of CSI parameters kept
+const maxParams = 32
+
+// maxSubParams bounds the number of ':' separated sub-parameters kept for
+// each CSI parameter
+const maxSubParams = 8
+
+// parser holds the escape sequence being decoded
+type parser struct {
+	state        int
+	params       [][]int // each parameter with its ':' separated sub-parameters
+	private      byte    // '?', '>', '<' or '=' prefix
+	intermediate byte
+	pending      []byte // incomplete UTF-8 sequence
+}
+
+// Write feeds terminal output to the screen. It never fails.
+func (s *Screen) Write(p []byte) (int, error) {
+	for i := 0; i < len(p); i++ {
+		b := p[i]
+
+		// Multi-byte UTF-8 is only meaningful as printable text
+		if s.parser.state == stateGround && (b >= 0x80 || len(s.parser.pending) > 0) {
+			if b < 0x80 || utf8.RuneStart(b) && len(s.parser.pending) > 0 {
+				// Truncated sequence interrupted by a new character
+				s.parser.pending = nil
+				s.print(utf8.RuneError)
+				if b < 0x80 {
+					s.handleByte(b)
+					continue
+				}
+			}
+			s.parser.pending = append(s.parser.pending, b)
+			if utf8.FullRune(s.parser.pending) {
+				r, _ := utf8.DecodeRune(s.parser.pending)
+				s.parser.pending = nil
+				s.print(r)
+			}
+			continue
+		}
+
+		s.handleByte(b)
+	}
+	return len(p), nil
+}
+
+// handleByte advances the parser by one byte
+func (s *Screen) handleByte(b byte) {
+	p := &s.parser
+
+	// CAN and SUB abort any sequence; ESC starts a new one
+	switch {
+	case b == 0x18 || b == 0x1a:
+		p.state = stateGround
+		return
+	case b == 0x1b && p.state != stateString:
+		s.beginEscape()
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		if b < 0x20 || b == 0x7f {
+			s.control(b)
+		} else {
+			s.print(rune(b))
+		}
+
+	case stateEscape:
+		switch {
+		case b < 0x20:
+			s.control(b)
+		case b == '[':
+			p.state = stateCSI
+		case b == ']' || b == 'P' || b == '_' || b == '^' || b == 'X':
+			p.state = stateString
+		case b >= 0x20 && b <= 0x2f:
+			p.intermediate = b
+			p.state = stateEscapeIntermediate
+		default:
+			p.state = stateGround
+			s.escape(b)
+		}
+
+	case stateEscapeIntermediate:
+		// Character set designations and the like are accepted and ignored,
+		// except for DECALN which fills the screen
+		if b < 0x20 {
+			s.control(b)
+			return
+		}
+		if b >= 0x30 {
+			if p.intermediate == '#' && b == '8' {
+				s.alignmentTest()
+			}
+			p.state = stateGround
+		}
+
+	case stateCSI:
+		s.csiByte(b)
+
+	case stateString:
+		switch b {
+		case 0x07:
+			p.state = stateGround
+		case 0x1b:
+			p.state = stateStringEscape
+		}
+
+	case stateStringEscape:
+		if b == '\\' {
+			p.state = stateGround
+		} else {
+			p.state = stateString
+		}
+	}
+}
+
+// beginEscape starts a new escape sequence
+func (s *Screen) beginEscape() {
+	s.parser.state = stateEscape
+	s.parser.params = s.parser.params[:0]
+	s.parser.private = 0
+	s.parser.intermediate = 0
+}
+
+// csiByte collects CSI parameters and dispatches the final byte
+func (s *Screen) csiByte(b byte) {
+	p := &s.parser
+	switch {
+	case b < 0x20:
+		s.control(b)
+	case b >= '0' && b <= '9':
+		if len(p.params) == 0 {
+			p.params = append(p.params, []int{0})
+		}
+		param := p.params[len(p.params)-1]
+		if v := param[len(param)-1]; v < 100000 {
+			param[len(param)-1] = v*10 + int(b-'0')
+		}
+	case b == ';':
+		if len(p.params) == 0 {
+			p.params = append(p.params, []int{0})
+		}
+		if len(p.params) < maxParams {
+			p.params = append(p.params, []int{0})
+		}
+	case b == ':':
+		if len(p.params) == 0 {
+			p.params = append(p.params, []int{0})
+		}
+		if param := p.params[len(p.params)-1]; len(param) < maxSubParams {
+			p.params[len(p.params)-1] = append(param, 0)
+		}
+	case b >= '<' && b <= '?':
+		p.private = b
+	case b >= 0x20 && b <= 0x2f:
+		p.intermediate = b
+	case b >= 0x40 && b <= 0x7e:
+		p.state = stateGround
+		s.csi(b)
+	default:
+		p.state = stateGround
+	}
+}
+
+// param returns CSI parameter i, or def if it is missing or zero
+func (s *Screen) param(i, def int) int {
+	if i < len(s.parser.params) && s.parser.params[i][0] != 0 {
+		return s.parser.params[i][0]
+	}
+	return def
+}
+
+// control executes a C0 control character
+func (s *Screen) control(b byte) {
+	switch b {
+	case '\b':
+		s.cur.wrapNext = false
+		if s.cur.col > 0 {
+			s.cur.col--
+		}
+	case '\t':
+		s.tab()
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\r':
+		s.cur.col = 0
+		s.cur.wrapNext = false
+	}
+}
+
+// escape executes a two-byte escape sequence
+func (s *Screen) escape(b byte) {
+	switch b {
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.cur.col = 0
+		s.lineFeed()
+	case 'H':
+		s.tabs[s.cur.col] = true
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset()
+	}
+}
+
+// csi executes a control sequence
+func (s *Screen) csi(final byte) {
+	p := &s.parser
+
+	// Private sequences other than mode changes (such as xterm key modifier
+	// settings) and sequences with intermediates don't affect the screen
+	if p.private != 0 && final != 'h' && final != 'l' {
+		return
+	}
+	if p.intermediate != 0 {
+		if p.intermediate == '!' && final == 'p' {
+			s.softReset()
+		}
+		return
+	}
+
+	switch final {
+	case 'A': // CUU
+		limit := 0
+		if s.cur.row >= s.top {
+			limit = s.top
+		}
+		s.moveTo(max(s.cur.row-s.param(0, 1), limit), s.cur.col)
+	case 'B', 'e': // CUD, VPR
+		limit := s.rows - 1
+		if s.cur.row <= s.bottom {
+			limit = s.bottom
+		}
+		s.moveTo(min(s.cur.row+s.param(0, 1), limit), s.cur.col)
+	case 'C', 'a': // CUF, HPR
+		s.moveTo(s.cur.row, s.cur.col+s.param(0, 1))
+	case 'D': // CUB
+		s.moveTo(s.cur.row, s.cur.col-s.param(0, 1))
+	case 'E': // CNL
+		s.moveTo(min(s.cur.row+s.param(0, 1), s.bottom), 0)
+	case 'F': // CPL
+		s.moveTo(max(s.cur.row-s.param(0, 1), s.top), 0)
+	case 'G', '`': // CHA, HPA
+		s.moveTo(s.cur.row, s.param(0, 1)-1)
+	case 'H', 'f': // CUP, HVP
+		row := s.param(0, 1) - 1
+		if s.cur.origin {
+			row = clamp(row+s.top, s.top, s.bottom)
+		}
+		s.moveTo(row, s.param(1, 1)-1)
+	case 'd': // VPA
+		s.moveTo(s.param(0, 1)-1, s.cur.col)
+	case 'I': // CHT
+		for i := 0; i < s.param(0, 1); i++ {
+			s.tab()
+		}
+	case 'Z': // CBT
+		for i := 0; i < s.param(0, 1); i++ {
+			col := s.cur.col - 1
+			for col > 0 && !s.tabs[col] {
+				col--
+			}
+			s.cur.col = max(col, 0)
+		}
+	case 'J': // ED
+		s.eraseDisplay(s.param(0, 0))
+	case 'K': // EL
+		s.eraseLine(s.param(0, 0))
+	case 'L': // IL
+		if s.cur.row >= s.top && s.cur.row <= s.bottom {
+			s.insertLinesAt(s.cur.row, s.param(0, 1))
+			s.cur.col = 0
+		}
+	case 'M': // DL
+		if s.cur.row >= s.top && s.cur.row <= s.bottom {
+			s.deleteLinesAt(s.cur.row, s.param(0, 1))
+			s.cur.col = 0
+		}
+	case '@': // ICH
+		s.insertChars(s.param(0, 1))
+	case 'P': // DCH
+		s.deleteChars(s.param(0, 1))
+	case 'X': // ECH
+		s.eraseCells(s.cur.row, s.cur.col, s.cur.col+s.param(0, 1))
+	case 'S': // SU
+		s.scrollUp(s.param(0, 1))
+	case 'T': // SD; the five parameter form is mouse tracking
+		if len(p.params) <= 1 {
+			s.scrollDown(s.param(0, 1))
+		}
+	case 'g': // TBC
+		switch s.param(0, 0) {
+		case 0:
+			s.tabs[s.cur.col] = false
+		case 3:
+			s.tabs = make([]bool, s.cols)
+		}
+	case 'm': // SGR
+		s.sgr()
+	case 'r': // DECSTBM
+		top := s.param(0, 1) - 1
+		bottom := s.param(1, s.rows) - 1
+		if top < bottom && bottom < s.rows {
+			s.top, s.bottom = top, bottom
+			if s.cur.origin {
+				s.moveTo(s.top, 0)
+			} else {
+				s.moveTo(0, 0)
+			}
+		}
+	case 's': // SCOSC
+		s.saveCursor()
+	case 'u': // SCORC
+		s.restoreCursor()
+	case 'h':
+		s.setModes(true)
+	case 'l':
+		s.setModes(false)
+	}
+}
+
+// eraseDisplay implements ED
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseCells(s.cur.row, s.cur.col, s.cols)
+		for row := s.cur.row + 1; row < s.rows; row++ {
+			s.eraseCells(row, 0, s.cols)
+		}
+	case 1:
+		for row := 0; row < s.cur.row; row++ {
+			s.eraseCells(row, 0, s.cols)
+		}
+		s.eraseCells(s.cur.row, 0, s.cur.col+1)
+	case 2:
+		for row := 0; row < s.rows; row++ {
+			s.eraseCells(row, 0, s.cols)
+		}
+	}
+	s.cur.wrapNext = false
+}
+
+// eraseLine implements EL
+func (s *Screen) eraseLine(mode int) {
+	switch mode {
+	case 0:
+		s.eraseCells(s.cur.row, s.cur.col, s.cols)
+	case 1:
+		s.eraseCells(s.cur.row, 0, s.cur.col+1)
+	case 2:
+		s.eraseCells(s.cur.row, 0, s.cols)
+	}
+	s.cur.wrapNext = false
+}
+
+// insertChars implements ICH
+func (s *Screen) insertChars(n int) {
+	line := s.grid()[s.cur.row]
+	n = clamp(n, 0, s.cols-s.cur.col)
+	copy(line[s.cur.col+n:], line[s.cur.col:s.cols-n])
+	s.eraseCells(s.cur.row, s.cur.col, s.cur.col+n)
+	s.cur.wrapNext = false
+}
+
+// deleteChars implements DCH
+func (s *Screen) deleteChars(n int) {
+	line := s.grid()[s.cur.row]
+	n = clamp(n, 0, s.cols-s.cur.col)
+	copy(line[s.cur.col:], line[s.cur.col+n:])
+	s.eraseCells(s.cur.row, s.cols-n, s.cols)
+	s.cur.wrapNext = false
+}
+
+// setModes implements SM/RM and DECSET/DECRST for the modes that change
+// what is on screen
+func (s *Screen) setModes(on bool) {
+	if s.parser.private != '?' {
+		return
+	}
+	for i := range s.parser.params {
+		switch s.parser.params[i][0] {
+		case 6: // DECOM
+			s.cur.origin = on
+			if on {
+				s.moveTo(s.top, 0)
+			} else {
+				s.moveTo(0, 0)
+			}
+		case 7: // DECAWM
+			s.autowrap = on
+			if !on {
+				s.cur.wrapNext = false
+			}
+		case 25: // DECTCEM
+			s.showCursor = on
+		case 47:
+			s.setAltScreen(on, false, false)
+		case 1047:
+			s.setAltScreen(on, false, !on)
+		case 1048:
+			if on {
+				s.saveCursor()
+			} else {
+				s.restoreCursor()
+			}
+		case 1049:
+			s.setAltScreen(on, true, true)
+		}
+	}
+}
+
+// softReset implements DECSTR
+func (s *Screen) softReset() {
+	s.cur.attr = DefaultAttr
+	s.cur.origin = false
+	s.cur.wrapNext = false
+	s.top, s.bottom = 0, s.rows-1
+	s.autowrap = true
+	s.showCursor = true
+}
+
+// alignmentTest implements DECALN, filling the screen with 'E'
+func (s *Screen) alignmentTest() {
+	for _, line := range s.grid() {
+		for i := range line {
+			line[i] = Cell{Rune: 'E', Attr: DefaultAttr}
+		}
+	}
+	s.moveTo(0, 0)
+}
+
+// sgr implements SGR, including 256-color and RGB colors in both the
+// ';' and ':' separated forms
+func (s *Screen) sgr() {
+	params := s.parser.params
+	if len(params) == 0 {
+		s.cur.attr = DefaultAttr
+		return
+	}
+
+	attr := &s.cur.attr
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		switch code := param[0]; {
+		case code == 0:
+			*attr = DefaultAttr
+		case code == 1:
+			attr.Bold = true
+		case code == 2:
+			attr.Dim = true
+		case code == 3:
+			attr.Italic = true
+		case code == 4:
+			attr.Underline = len(param) < 2 || param[1] != 0
+		case code == 5 || code == 6:
+			attr.Blink = true
+		case code == 7:
+			attr.Inverse = true
+		case code == 8:
+			attr.Hidden = true
+		case code == 9:
+			attr.Strike = true
+		case code == 21:
+			attr.Underline = true
+		case code == 22:
+			attr.Bold = false
+			attr.Dim = false
+		case code == 23:
+			attr.Italic = false
+		case code == 24:
+			attr.Underline = false
+		case code == 25:
+			attr.Blink = false
+		case code == 27:
+			attr.Inverse = false
+		case code == 28:
+			attr.Hidden = false
+		case code == 29:
+			attr.Strike = false
+		case code >= 30 && code <= 37:
+			attr.FG = Color(code - 30)
+		case code == 38 || code == 48 || code == 58:
+			color, consumed, ok := extendedColor(params, i)
+			i += consumed
+			if !ok {
+				continue
+			}
+			if code == 38 {
+				attr.FG = color
+			} else if code == 48 {
+				attr.BG = color
+			}
+		case code == 39:
+			attr.FG = DefaultColor
+		case code >= 40 && code <= 47:
+			attr.BG = Color(code - 40)
+		case code == 49:
+			attr.BG = DefaultColor
+		case code >= 90 && code <= 97:
+			attr.FG = Color(code - 90 + 8)
+		case code >= 100 && code <= 107:
+			attr.BG = Color(code - 100 + 8)
+		}
+	}
+}
+
+// extendedColor decodes the color following a 38, 48 or 58 parameter at
+// index i. It returns the number of extra ';' separated parameters used.
+func extendedColor(params [][]int, i int) (Color, int, bool) {
+	param := params[i]
+
+	// Colon form: 38:5:n, 38:2:r:g:b or 38:2:colorspace:r:g:b
+	if len(param) > 1 {
+		switch {
+		case param[1] == 5 && len(param) >= 3:
+			return Color(clamp(param[2], 0, 255)), 0, true
+		case param[1] == 2 && len(param) >= 5:
+			rgb := param[len(param)-3:]
+			return RGB(uint8(rgb[0]), uint8(rgb[1]), uint8(rgb[2])), 0, true
+		}
+		return 0, 0, false
+	}
+
+	// Semicolon form: 38;5;n or 38;2;r;g;b
+	if i+1 >= len(params) {
+		return 0, 0, false
+	}
+	switch params[i+1][0] {
+	case 5:
+		if i+2 < len(params) {
+			return Color(clamp(params[i+2][0], 0, 255)), 2, true
+		}
+	case 2:
+		if i+4 < len(params) {
+			return RGB(uint8(params[i+2][0]), uint8(params[i+3][0]), uint8(params[i+4][0])), 4, true
+		}
+	}
+	return 0, len(params) - i - 1, false
+}
@@ -0,0 +1,386 @@
+package screen
+
+// Color is a cell color: DefaultColor, a palette index from 0 to 255, or a
+// 24-bit RGB value created with RGB
+type Color int32
+
+// DefaultColor is the terminal's default foreground or background
+const DefaultColor Color = -1
+
+// rgbFlag marks a Color as a 24-bit RGB value
+const rgbFlag = 1 << 24
+
+// RGB returns a 24-bit color
+func RGB(r, g, b uint8) Color {
+	return Color(rgbFlag | int32(r)<<16 | int32(g)<<8 | int32(b))
+}
+
+// IsRGB reports whether c is a 24-bit color
+func (c Color) IsRGB() bool {
+	return c >= rgbFlag
+}
+
+// RGB returns the components of a 24-bit color
+func (c Color) RGB() (r, g, b uint8) {
+	return uint8(c >> 16), uint8(c >> 8), uint8(c)
+}
+
+// Attr holds the graphic rendition of a cell
+type Attr struct {
+	FG        Color
+	BG        Color
+	Bold      bool
+	Dim       bool
+	Italic    bool
+	Underline bool
+	Blink     bool
+	Inverse   bool
+	Hidden    bool
+	Strike    bool
+}
+
+// DefaultAttr is the rendition after an SGR reset
+var DefaultAttr = Attr{FG: DefaultColor, BG: DefaultColor}
+
+// Cell is one character position on the screen. The second column of a
+// wide character holds a zero Rune.
+type Cell struct {
+	Rune rune
+	Attr Attr
+}
+
+// cursor is the cursor state saved and restored by DECSC/DECRC
+type cursor struct {
+	row, col int
+	attr     Attr
+	wrapNext bool // the next printed character wraps to a new line first
+	origin   bool // cursor addressing is relative to the scroll region
+}
+
+// Screen is a VT100/xterm screen model. It tracks what a terminal of the
+// given size would display after receiving the output written to it:
+// cursor movement, erasing, scroll regions, the alternate screen and SGR
+// attributes. It is not safe for concurrent use.
+type Screen struct {
+	rows, cols int
+
+	main, alt [][]Cell
+	altActive bool
+
+	cur        cursor
+	savedMain  cursor
+	savedAlt   cursor
+	top        int // scroll region, inclusive
+	bottom     int
+	autowrap   bool
+	showCursor bool
+	tabs       []bool
+
+	parser parser
+}
+
+// New creates a blank screen
+func New(rows, cols int) *Screen {
+	if rows < 1 {
+		rows = 1
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	s := &Screen{rows: rows, cols: cols}
+	s.reset()
+	return s
+}
+
+// reset returns the screen to its power-on state (RIS)
+func (s *Screen) reset() {
+	s.main = newGrid(s.rows, s.cols)
+	s.alt = newGrid(s.rows, s.cols)
+	s.altActive = false
+	s.cur = cursor{attr: DefaultAttr}
+	s.savedMain = s.cur
+	s.savedAlt = s.cur
+	s.top = 0
+	s.bottom = s.rows - 1
+	s.autowrap = true
+	s.showCursor = true
+	s.resetTabs()
+}
+
+// Size returns the screen dimensions
+func (s *Screen) Size() (rows, cols int) {
+	return s.rows, s.cols
+}
+
+// Cursor returns the cursor position and whether it is visible
+func (s *Screen) Cursor() (row, col int, visible bool) {
+	return s.cur.row, s.cur.col, s.showCursor
+}
+
+// AltScreen reports whether the alternate screen is active
+func (s *Screen) AltScreen() bool {
+	return s.altActive
+}
+
+// Cell returns the cell at row, col
+func (s *Screen) Cell(row, col int) Cell {
+	if row < 0 || row >= s.rows || col < 0 || col >= s.cols {
+		return blankCell(DefaultAttr)
+	}
+	return s.grid()[row][col]
+}
+
+// Resize changes the screen size, keeping content anchored at the top left.
+// When rows shrink below the cursor, lines scroll off the top so the cursor
+// stays on screen, as xterm does.
+func (s *Screen) Resize(rows, cols int) {
+	if rows < 1 || cols < 1 || (rows == s.rows && cols == s.cols) {
+		return
+	}
+
+	shift := 0
+	if s.cur.row >= rows {
+		shift = s.cur.row - rows + 1
+	}
+	s.main = resizeGrid(s.main, rows, cols, shift)
+	s.alt = resizeGrid(s.alt, rows, cols, shift)
+
+	s.rows, s.cols = rows, cols
+	s.top, s.bottom = 0, rows-1
+	s.cur.row -= shift
+	s.clampCursor()
+	s.savedMain.row = clamp(s.savedMain.row, 0, rows-1)
+	s.savedMain.col = clamp(s.savedMain.col, 0, cols-1)
+	s.savedAlt.row = clamp(s.savedAlt.row, 0, rows-1)
+	s.savedAlt.col = clamp(s.savedAlt.col, 0, cols-1)
+	s.resetTabs()
+}
+
+// grid returns the active buffer
+func (s *Screen) grid() [][]Cell {
+	if s.altActive {
+		return s.alt
+	}
+	return s.main
+}
+
+// print places a character at the cursor and advances it
+func (s *Screen) print(r rune) {
+	width := runeWidth(r)
+	if width == 0 {
+		return
+	}
+
+	if s.cur.wrapNext {
+		s.cur.wrapNext = false
+		s.cur.col = 0
+		s.lineFeed()
+	}
+
+	// A wide character that does not fit wraps as a whole
+	if width == 2 && s.cur.col == s.cols-1 {
+		if !s.autowrap || s.cols < 2 {
+			return
+		}
+		s.grid()[s.cur.row][s.cur.col] = blankCell(s.cur.attr)
+		s.cur.col = 0
+		s.lineFeed()
+	}
+
+	line := s.grid()[s.cur.row]
+	line[s.cur.col] = Cell{Rune: r, Attr: s.cur.attr}
+	if width == 2 {
+		line[s.cur.col+1] = Cell{Rune: 0, Attr: s.cur.attr}
+	}
+
+	if s.cur.col+width >= s.cols {
+		s.cur.col = s.cols - 1
+		s.cur.wrapNext = s.autowrap
+	} else {
+		s.cur.col += width
+	}
+}
+
+// lineFeed moves the cursor down, scrolling at the bottom of the region
+func (s *Screen) lineFeed() {
+	s.cur.wrapNext = false
+	if s.cur.row == s.bottom {
+		s.scrollUp(1)
+	} else if s.cur.row < s.rows-1 {
+		s.cur.row++
+	}
+}
+
+// reverseIndex moves the cursor up, scrolling at the top of the region
+func (s *Screen) reverseIndex() {
+	s.cur.wrapNext = false
+	if s.cur.row == s.top {
+		s.scrollDown(1)
+	} else if s.cur.row > 0 {
+		s.cur.row--
+	}
+}
+
+// scrollUp scrolls the scroll region up by n lines
+func (s *Screen) scrollUp(n int) {
+	s.deleteLinesAt(s.top, n)
+}
+
+// scrollDown scrolls the scroll region down by n lines
+func (s *Screen) scrollDown(n int) {
+	s.insertLinesAt(s.top, n)
+}
+
+// insertLinesAt inserts n blank lines at row, pushing lines below it down
+// and off the bottom of the scroll region
+func (s *Screen) insertLinesAt(row, n int) {
+	grid := s.grid()
+	n = clamp(n, 0, s.bottom-row+1)
+	copy(grid[row+n:s.bottom+1], grid[row:s.bottom+1-n])
+	for i := row; i < row+n; i++ {
+		grid[i] = newLine(s.cols, s.eraseAttr())
+	}
+}
+
+// deleteLinesAt deletes n lines at row, pulling lines below it up and
+// adding blank lines at the bottom of the scroll region
+func (s *Screen) deleteLinesAt(row, n int) {
+	grid := s.grid()
+	n = clamp(n, 0, s.bottom-row+1)
+	copy(grid[row:s.bottom+1-n], grid[row+n:s.bottom+1])
+	for i := s.bottom + 1 - n; i <= s.bottom; i++ {
+		grid[i] = newLine(s.cols, s.eraseAttr())
+	}
+}
+
+// eraseCells blanks columns [from, to) of a row
+func (s *Screen) eraseCells(row, from, to int) {
+	line := s.grid()[row]
+	from = clamp(from, 0, s.cols)
+	to = clamp(to, 0, s.cols)
+	for i := from; i < to; i++ {
+		line[i] = blankCell(s.eraseAttr())
+	}
+}
+
+// eraseAttr is used for erased cells: like xterm, they keep the current
+// background color
+func (s *Screen) eraseAttr() Attr {
+	attr := DefaultAttr
+	attr.BG = s.cur.attr.BG
+	return attr
+}
+
+// moveTo positions the cursor, clamped to the screen
+func (s *Screen) moveTo(row, col int) {
+	s.cur.row = clamp(row, 0, s.rows-1)
+	s.cur.col = clamp(col, 0, s.cols-1)
+	s.cur.wrapNext = false
+}
+
+// clampCursor keeps the cursor on screen
+func (s *Screen) clampCursor() {
+	s.moveTo(s.cur.row, s.cur.col)
+}
+
+// setAltScreen switches between the main and alternate screens
+func (s *Screen) setAltScreen(on, saveCursor, clear bool) {
+	if on == s.altActive {
+		return
+	}
+	if on {
+		if saveCursor {
+			s.savedMain = s.cur
+		}
+		s.altActive = true
+		if clear {
+			s.alt = newGrid(s.rows, s.cols)
+		}
+	} else {
+		if clear {
+			s.alt = newGrid(s.rows, s.cols)
+		}
+		s.altActive = false
+		if saveCursor {
+			s.cur = s.savedMain
+			s.clampCursor()
+		}
+	}
+}
+
+// saveCursor implements DECSC
+func (s *Screen) saveCursor() {
+	if s.altActive {
+		s.savedAlt = s.cur
+	} else {
+		s.savedMain = s.cur
+	}
+}
+
+// restoreCursor implements DECRC
+func (s *Screen) restoreCursor() {
+	if s.altActive {
+		s.cur = s.savedAlt
+	} else {
+		s.cur = s.savedMain
+	}
+	s.clampCursor()
+}
+
+// tab advances the cursor to the next tab stop
+func (s *Screen) tab() {
+	for col := s.cur.col + 1; col < s.cols; col++ {
+		if s.tabs[col] {
+			s.cur.col = col
+			return
+		}
+	}
+	s.cur.col = s.cols - 1
+}
+
+// resetTabs sets a tab stop every eight columns
+func (s *Screen) resetTabs() {
+	s.tabs = make([]bool, s.cols)
+	for col := 8; col < s.cols; col += 8 {
+		s.tabs[col] = true
+	}
+}
+
+func blankCell(attr Attr) Cell {
+	return Cell{Rune: ' ', Attr: attr}
+}
+
+func newLine(cols int, attr Attr) []Cell {
+	line := make([]Cell, cols)
+	for i := range line {
+		line[i] = blankCell(attr)
+	}
+	return line
+}
+
+func newGrid(rows, cols int) [][]Cell {
+	grid := make([][]Cell, rows)
+	for i := range grid {
+		grid[i] = newLine(cols, DefaultAttr)
+	}
+	return grid
+}
+
+// resizeGrid copies grid into a new size, dropping shift lines off the top
+func resizeGrid(grid [][]Cell, rows, cols, shift int) [][]Cell {
+	resized := newGrid(rows, cols)
+	for row := 0; row < rows && row+shift < len(grid); row++ {
+		copy(resized[row], grid[row+shift])
+	}
+	return resized
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
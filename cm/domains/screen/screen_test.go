@@ -0,0 +1,146 @@
+package screen
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func write(s *Screen, data string) {
+	s.Write([]byte(data))
+}
+
+func TestScreenText(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   int
+		cols   int
+		output string
+		want   string
+	}{
+		{name: "plain lines", rows: 3, cols: 10, output: "hello\r\nworld", want: "hello\nworld\n"},
+		{name: "cursor movement", rows: 3, cols: 10, output: "abc\x1b[2;5Hx\x1b[Ay\x1b[3Dz", want: "abcz y\n    x\n"},
+		{name: "erase to end of line", rows: 2, cols: 10, output: "abcdef\x1b[1;3H\x1b[K", want: "ab\n"},
+		{name: "erase display", rows: 2, cols: 10, output: "one\r\ntwo\x1b[2J", want: "\n"},
+		{name: "autowrap", rows: 3, cols: 4, output: "abcdefg", want: "abcd\nefg\n"},
+		{name: "scroll at bottom", rows: 2, cols: 5, output: "1\r\n2\r\n3", want: "2\n3"},
+		{name: "scroll region", rows: 4, cols: 5, output: "top\x1b[2;3r\x1b[2;1Ha\r\nb\r\nc\x1b[r\x1b[4;1Hend", want: "top\nb\nc\nend"},
+		{name: "insert and delete chars", rows: 1, cols: 10, output: "abcdef\x1b[1;2H\x1b[2P\x1b[1@", want: "a def"},
+		{name: "backspace and tab", rows: 1, cols: 20, output: "ab\bc\tx", want: "ac      x"},
+		{name: "wide characters", rows: 1, cols: 6, output: "日本x", want: "日本x"},
+		{name: "osc title ignored", rows: 1, cols: 10, output: "\x1b]0;title\x07ok", want: "ok"},
+		{name: "split utf-8", rows: 1, cols: 10, output: "caf\xc3\xa9", want: "café"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.rows, tt.cols)
+			write(s, tt.output)
+			if got := s.Text(); got != tt.want {
+				t.Errorf("Text() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenAltScreen(t *testing.T) {
+	s := New(3, 10)
+	write(s, "shell$ ")
+	write(s, "\x1b[?1049h\x1b[Heditor")
+	if !s.AltScreen() || s.Text() != "editor\n\n" {
+		t.Fatalf("alt screen text = %q", s.Text())
+	}
+
+	write(s, "\x1b[?1049l")
+	if s.AltScreen() || s.Text() != "shell$\n\n" {
+		t.Errorf("main screen text = %q", s.Text())
+	}
+	if row, col, _ := s.Cursor(); row != 0 || col != 7 {
+		t.Errorf("cursor = %d,%d, want 0,7", row, col)
+	}
+}
+
+func TestScreenSGR(t *testing.T) {
+	s := New(1, 20)
+	write(s, "\x1b[1;31ma\x1b[38;5;200;48;2;1;2;3mb\x1b[38:2::10:20:30mc\x1b[0md")
+
+	tests := []struct {
+		col  int
+		want Attr
+	}{
+		{0, Attr{FG: 1, BG: DefaultColor, Bold: true}},
+		{1, Attr{FG: 200, BG: RGB(1, 2, 3), Bold: true}},
+		{2, Attr{FG: RGB(10, 20, 30), BG: RGB(1, 2, 3), Bold: true}},
+		{3, DefaultAttr},
+	}
+	for _, tt := range tests {
+		if got := s.Cell(0, tt.col).Attr; got != tt.want {
+			t.Errorf("cell %d attr = %+v, want %+v", tt.col, got, tt.want)
+		}
+	}
+}
+
+func TestScreenParamBounds(t *testing.T) {
+	s := New(1, 20)
+	write(s, "\x1b[38"+strings.Repeat(":1", 1000)+strings.Repeat(";1", 1000))
+	if len(s.parser.params) != maxParams || len(s.parser.params[0]) != maxSubParams {
+		t.Fatalf("kept %d params, %d sub-parameters", len(s.parser.params), len(s.parser.params[0]))
+	}
+	write(s, "mx")
+	if got := s.Text(); got != "x" {
+		t.Errorf("text = %q, want %q", got, "x")
+	}
+}
+
+func TestScreenSnapshotJSON(t *testing.T) {
+	s := New(2, 10)
+	write(s, "\x1b[32mok\x1b[0m!")
+
+	data, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"lines":[[{"text":"ok","fg":2,"bg":null},{"text":"!","fg":null,"bg":null}],[]]`
+	if !strings.Contains(string(data), want) {
+		t.Errorf("snapshot = %s, want it to contain %s", data, want)
+	}
+}
+
+func TestScreenRepaint(t *testing.T) {
+	s := New(5, 12)
+	write(s, "\x1b[44mblue\x1b[0m line\r\n\x1b[1mbold\x1b[0m\r\n日本語\x1b[2;4r\x1b[5;3H\x1b[?25l")
+
+	// Drawing the repaint on a fresh screen must reproduce the original
+	repainted := New(5, 12)
+	write(repainted, "garbage\x1b[?1049h")
+	repainted.Write(s.Repaint())
+
+	if repainted.Text() != s.Text() {
+		t.Errorf("repainted text = %q, want %q", repainted.Text(), s.Text())
+	}
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 12; col++ {
+			if got, want := repainted.Cell(row, col), s.Cell(row, col); got != want {
+				t.Errorf("cell %d,%d = %+v, want %+v", row, col, got, want)
+			}
+		}
+	}
+	if repainted.Snapshot().CursorRow != 4 || repainted.Snapshot().CursorCol != 2 || repainted.Snapshot().Cursor {
+		t.Errorf("cursor not restored: %+v", repainted.Snapshot())
+	}
+	if repainted.top != 1 || repainted.bottom != 3 {
+		t.Errorf("scroll region = %d-%d, want 1-3", repainted.top, repainted.bottom)
+	}
+}
+
+func TestScreenResize(t *testing.T) {
+	s := New(3, 10)
+	write(s, "one\r\ntwo\r\nthree")
+	s.Resize(2, 4)
+	if got := s.Text(); got != "two\nthre" {
+		t.Errorf("Text() after resize = %q", got)
+	}
+	if row, _, _ := s.Cursor(); row != 1 {
+		t.Errorf("cursor row = %d, want 1", row)
+	}
+}
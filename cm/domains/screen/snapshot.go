@@ -0,0 +1,218 @@
+package screen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Text returns the visible screen as plain text, one line per row with
+// trailing blanks removed
+func (s *Screen) Text() string {
+	var b strings.Builder
+	for row, line := range s.grid() {
+		if row > 0 {
+			b.WriteByte('\n')
+		}
+		var text strings.Builder
+		for _, cell := range line {
+			if cell.Rune != 0 {
+				text.WriteRune(cell.Rune)
+			}
+		}
+		b.WriteString(strings.TrimRight(text.String(), " "))
+	}
+	return b.String()
+}
+
+// Snapshot is the visible screen with its attributes
+type Snapshot struct {
+	Rows      int      `json:"rows"`
+	Cols      int      `json:"cols"`
+	CursorRow int      `json:"cursorRow"`
+	CursorCol int      `json:"cursorCol"`
+	Cursor    bool     `json:"cursorVisible"`
+	AltScreen bool     `json:"altScreen"`
+	Lines     [][]Span `json:"lines"`
+}
+
+// Span is a run of cells on one line sharing the same attributes
+type Span struct {
+	Text string `json:"text"`
+	Attr
+}
+
+// MarshalJSON encodes the default color as null, palette colors as their
+// index and RGB colors as "#rrggbb"
+func (c Color) MarshalJSON() ([]byte, error) {
+	switch {
+	case c == DefaultColor:
+		return []byte("null"), nil
+	case c.IsRGB():
+		r, g, b := c.RGB()
+		return json.Marshal(fmt.Sprintf("#%02x%02x%02x", r, g, b))
+	}
+	return []byte(strconv.Itoa(int(c))), nil
+}
+
+// MarshalJSON encodes only the attributes that are set
+func (a Attr) MarshalJSON() ([]byte, error) {
+	type attrJSON struct {
+		FG        Color `json:"fg"`
+		BG        Color `json:"bg"`
+		Bold      bool  `json:"bold,omitempty"`
+		Dim       bool  `json:"dim,omitempty"`
+		Italic    bool  `json:"italic,omitempty"`
+		Underline bool  `json:"underline,omitempty"`
+		Blink     bool  `json:"blink,omitempty"`
+		Inverse   bool  `json:"inverse,omitempty"`
+		Hidden    bool  `json:"hidden,omitempty"`
+		Strike    bool  `json:"strike,omitempty"`
+	}
+	return json.Marshal(attrJSON(a))
+}
+
+// MarshalJSON flattens the span's attributes next to its text
+func (sp Span) MarshalJSON() ([]byte, error) {
+	attr, err := sp.Attr.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	text, err := json.Marshal(sp.Text)
+	if err != nil {
+		return nil, err
+	}
+	// attr is a non-empty JSON object, so splice the text in as its first key
+	return append([]byte(`{"text":`+string(text)+","), attr[1:]...), nil
+}
+
+// Snapshot returns the visible screen, grouping cells into spans of equal
+// attributes. Trailing blank cells with default attributes are dropped.
+func (s *Screen) Snapshot() Snapshot {
+	snap := Snapshot{
+		Rows:      s.rows,
+		Cols:      s.cols,
+		CursorRow: s.cur.row,
+		CursorCol: s.cur.col,
+		Cursor:    s.showCursor,
+		AltScreen: s.altActive,
+		Lines:     make([][]Span, s.rows),
+	}
+	for row, line := range s.grid() {
+		spans := []Span{}
+		line = trimLine(line)
+		for col := 0; col < len(line); {
+			attr := line[col].Attr
+			var text strings.Builder
+			for ; col < len(line) && line[col].Attr == attr; col++ {
+				if line[col].Rune != 0 {
+					text.WriteRune(line[col].Rune)
+				}
+			}
+			spans = append(spans, Span{Text: text.String(), Attr: attr})
+		}
+		snap.Lines[row] = spans
+	}
+	return snap
+}
+
+// Repaint returns output that reproduces the current screen on a terminal
+// of the same size, much shorter than replaying everything that produced it
+func (s *Screen) Repaint() []byte {
+	var b strings.Builder
+
+	if s.altActive {
+		b.WriteString("\x1b[?1049h")
+	} else {
+		b.WriteString("\x1b[?1049l")
+	}
+	b.WriteString("\x1b[r\x1b[?6l\x1b[?7h\x1b[0m\x1b[H\x1b[2J")
+
+	attr := DefaultAttr
+	for row, line := range s.grid() {
+		line = trimLine(line)
+		if len(line) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\x1b[%d;1H", row+1)
+		for _, cell := range line {
+			if cell.Rune == 0 {
+				continue
+			}
+			if cell.Attr != attr {
+				b.WriteString(sgrSequence(cell.Attr))
+				attr = cell.Attr
+			}
+			b.WriteRune(cell.Rune)
+		}
+	}
+
+	if s.top != 0 || s.bottom != s.rows-1 {
+		fmt.Fprintf(&b, "\x1b[%d;%dr", s.top+1, s.bottom+1)
+	}
+	if !s.autowrap {
+		b.WriteString("\x1b[?7l")
+	}
+	if s.cur.origin {
+		// Addressing is relative to the scroll region once DECOM is set
+		fmt.Fprintf(&b, "\x1b[?6h\x1b[%d;%dH", s.cur.row-s.top+1, s.cur.col+1)
+	} else {
+		fmt.Fprintf(&b, "\x1b[%d;%dH", s.cur.row+1, s.cur.col+1)
+	}
+	b.WriteString(sgrSequence(s.cur.attr))
+	if s.showCursor {
+		b.WriteString("\x1b[?25h")
+	} else {
+		b.WriteString("\x1b[?25l")
+	}
+	return []byte(b.String())
+}
+
+// trimLine drops trailing blank cells with default attributes
+func trimLine(line []Cell) []Cell {
+	end := len(line)
+	for end > 0 && line[end-1] == blankCell(DefaultAttr) {
+		end--
+	}
+	return line[:end]
+}
+
+// sgrSequence returns the SGR sequence that selects attr from scratch
+func sgrSequence(attr Attr) string {
+	codes := []string{"0"}
+	flags := []struct {
+		on   bool
+		code string
+	}{
+		{attr.Bold, "1"}, {attr.Dim, "2"}, {attr.Italic, "3"}, {attr.Underline, "4"},
+		{attr.Blink, "5"}, {attr.Inverse, "7"}, {attr.Hidden, "8"}, {attr.Strike, "9"},
+	}
+	for _, flag := range flags {
+		if flag.on {
+			codes = append(codes, flag.code)
+		}
+	}
+	if attr.FG != DefaultColor {
+		codes = append(codes, colorCode(attr.FG, 30, 90, 38))
+	}
+	if attr.BG != DefaultColor {
+		codes = append(codes, colorCode(attr.BG, 40, 100, 48))
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+// colorCode returns the SGR parameters for a color, using the short forms
+// for the 16 basic colors
+func colorCode(c Color, base, bright, extended int) string {
+	switch {
+	case c.IsRGB():
+		r, g, b := c.RGB()
+		return fmt.Sprintf("%d;2;%d;%d;%d", extended, r, g, b)
+	case c < 8:
+		return strconv.Itoa(base + int(c))
+	case c < 16:
+		return strconv.Itoa(bright + int(c) - 8)
+	}
+	return fmt.Sprintf("%d;5;%d", extended, c)
+}
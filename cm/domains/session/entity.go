@@ -3,34 +3,262 @@ package session
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 )
 
 // Session represents a Claude Code session
 type Session struct {
-	ID       string    `json:"id"`
-	Name     string    `json:"name"`
-	Path     string    `json:"path"`
-	Branch   string    `json:"branch"`
-	PID      int       `json:"pid"`
-	Status   string    `json:"status"`
-	Created  time.Time `json:"created"`
-	LastSeen time.Time `json:"last_seen"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Branch    string    `json:"branch"`
+	PID       int       `json:"pid"`
+	Profile   string    `json:"profile"` // agent profile the session was started with
+	Status    string    `json:"status"`  // see the Status constants
+	Ready     bool      `json:"ready"`   // the agent has finished starting up
+	Clients   int       `json:"clients"` // clients attached to the terminal
+	Rows      uint16    `json:"rows"`
+	Cols      uint16    `json:"cols"`
+	Recording string    `json:"recording,omitempty"` // asciicast file being written, if any
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// Activity is working, idle or awaiting_input, as seen from the terminal
+	Activity      string    `json:"activity"`
+	ActivitySince time.Time `json:"activity_since"`
+	LastOutput    time.Time `json:"last_output"`
+
+	// How the agent's last run ended
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	ExitSignal string     `json:"exit_signal,omitempty"` // signal that killed the agent, if any
+	Reason     string     `json:"reason,omitempty"`      // why the agent failed
+	KilledBy   string     `json:"killed_by,omitempty"`
+	Ended      *time.Time `json:"ended,omitempty"`
+	Launched   *time.Time `json:"launched,omitempty"` // when the last run started
+	Launches   int        `json:"launches"`
+
+	// Restarts made by the restart policy since the agent last stayed up
+	Restart  *RestartPolicy `json:"restart,omitempty"`
+	Restarts int            `json:"restarts"`
+
+	// How the session was launched
+	Command     []string       `json:"command,omitempty"`
+	Claude      *ClaudeOptions `json:"claude,omitempty"`
+	Environment *EnvOptions    `json:"environment,omitempty"` // secret values are masked
+
+	// Resources used by the session's processes, sampled periodically
+	CPUPercent float64 `json:"cpu_percent"` // of one core, so may exceed 100
+	MemoryRSS  int64   `json:"memory_rss"`  // bytes
+	Processes  int     `json:"processes"`
+
+	// Limits applied to the session and how they are enforced
+	Limits    *Limits `json:"limits,omitempty"`
+	LimitMode string  `json:"limit_mode,omitempty"` // "cgroup" or "rlimit"
+
+	Events []Event `json:"events,omitempty"` // most recent last
+}
+
+// Event is something notable that happened to a session
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+// maxEvents bounds the events kept on a session
+const maxEvents = 50
+
+// Limits caps the resources a session's processes may use. Zero values
+// leave a resource unlimited.
+type Limits struct {
+	CPUWeight int     `json:"cpuWeight"` // relative share of CPU, 1-10000 (default 100)
+	CPUQuota  float64 `json:"cpuQuota"`  // CPUs' worth of time, e.g. 1.5
+	MemoryMax int64   `json:"memoryMax"` // bytes
+	PidsMax   int     `json:"pidsMax"`   // processes and threads
+	Nice      int     `json:"nice"`      // scheduling priority, -20 to 19
+}
+
+// IsZero reports whether no limits are set
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Validate checks that the limits are within range
+func (l Limits) Validate() error {
+	switch {
+	case l.CPUWeight < 0 || l.CPUWeight > 10000:
+		return fmt.Errorf("cpuWeight must be between 1 and 10000")
+	case l.CPUQuota < 0:
+		return fmt.Errorf("cpuQuota must not be negative")
+	case l.MemoryMax < 0:
+		return fmt.Errorf("memoryMax must not be negative")
+	case l.PidsMax < 0:
+		return fmt.Errorf("pidsMax must not be negative")
+	case l.Nice < -20 || l.Nice > 19:
+		return fmt.Errorf("nice must be between -20 and 19")
+	}
+	return nil
 }
 
 // CreateRequest represents a session creation request
 type CreateRequest struct {
-	Name        string `json:"name"`
-	RepoPath    string `json:"repoPath"`
-	BranchName  string `json:"branchName"`
-	BaseBranch  string `json:"baseBranch"`
-	UseWorktree bool   `json:"useWorktree"`
+	Name        string        `json:"name"`
+	RepoPath    string        `json:"repoPath"`
+	BranchName  string        `json:"branchName"`
+	BaseBranch  string        `json:"baseBranch"`
+	UseWorktree bool          `json:"useWorktree"`
+	Record      bool          `json:"record"`      // record the session in asciicast format
+	RecordInput bool          `json:"recordInput"` // include keystrokes in the recording
+	Limits      Limits        `json:"limits"`      // resource limits for the session's processes
+	Profile     string        `json:"profile"`     // agent profile to launch; Claude, or a shell without it, by default
+	Restart     RestartPolicy `json:"restart"`     // whether the agent is started again when it exits
+	ClaudeOptions
+	EnvOptions
+}
+
+// MaskedValue replaces the values of secret variables in API responses
+const MaskedValue = "********"
+
+// EnvVar is an environment variable set for a session
+type EnvVar struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"` // masked whenever returned from the API
+}
+
+// MarshalJSON masks the value of secret variables
+func (v EnvVar) MarshalJSON() ([]byte, error) {
+	type plain EnvVar
+	if v.Secret {
+		v.Value = MaskedValue
+	}
+	return json.Marshal(plain(v))
+}
+
+// EnvOptions shape a session's environment. It starts as the server's
+// environment less EnvDeny, then the variables in EnvFiles are added, then
+// Env, each overriding what came before.
+type EnvOptions struct {
+	Env      []EnvVar `json:"env,omitempty"`
+	EnvFiles []string `json:"envFiles,omitempty"` // .env files, relative to the repository unless absolute
+	EnvDeny  []string `json:"envDeny,omitempty"`  // server variables to drop; a trailing * matches a prefix
+}
+
+// IsZero reports whether no options are set
+func (o EnvOptions) IsZero() bool {
+	return len(o.Env) == 0 && len(o.EnvFiles) == 0 && len(o.EnvDeny) == 0
+}
+
+// Permission modes Claude can be started in
+var PermissionModes = []string{"default", "acceptEdits", "plan", "bypassPermissions"}
+
+// maxPromptSize bounds an initial prompt
+const maxPromptSize = 64 * 1024
+
+// modelName matches model aliases and full model names
+var modelName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:\[\]-]*$`)
+
+// ClaudeOptions are command line options for sessions running Claude
+type ClaudeOptions struct {
+	Prompt          string   `json:"prompt,omitempty"` // initial prompt
+	Model           string   `json:"model,omitempty"`
+	PermissionMode  string   `json:"permissionMode,omitempty"`
+	AllowedTools    []string `json:"allowedTools,omitempty"`
+	DisallowedTools []string `json:"disallowedTools,omitempty"`
+	AddDirs         []string `json:"addDirs,omitempty"`   // further directories Claude may use, as absolute paths
+	MCPConfig       string   `json:"mcpConfig,omitempty"` // path to an MCP config file, or the JSON itself
+}
+
+// IsZero reports whether no options are set
+func (o ClaudeOptions) IsZero() bool {
+	return o.Prompt == "" && o.Model == "" && o.PermissionMode == "" && len(o.AllowedTools) == 0 &&
+		len(o.DisallowedTools) == 0 && len(o.AddDirs) == 0 && o.MCPConfig == ""
+}
+
+// Validate checks the options, including that the directories and MCP
+// config file they name exist
+func (o ClaudeOptions) Validate() error {
+	if len(o.Prompt) > maxPromptSize {
+		return fmt.Errorf("prompt is longer than %d bytes", maxPromptSize)
+	}
+	if strings.ContainsRune(o.Prompt, 0) {
+		return fmt.Errorf("prompt contains a NUL byte")
+	}
+	if o.Model != "" && !modelName.MatchString(o.Model) {
+		return fmt.Errorf("invalid model %q", o.Model)
+	}
+	if o.PermissionMode != "" && !contains(PermissionModes, o.PermissionMode) {
+		return fmt.Errorf("permissionMode must be one of %s", strings.Join(PermissionModes, ", "))
+	}
+	for _, tool := range append(append([]string(nil), o.AllowedTools...), o.DisallowedTools...) {
+		if strings.TrimSpace(tool) == "" || strings.ContainsAny(tool, ",\n\x00") || strings.HasPrefix(tool, "-") {
+			return fmt.Errorf("invalid tool %q", tool)
+		}
+	}
+	for _, dir := range o.AddDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("addDirs must be absolute paths: %q", dir)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("addDirs: %q is not a directory", dir)
+		}
+	}
+	if config := strings.TrimSpace(o.MCPConfig); strings.HasPrefix(config, "{") {
+		if !json.Valid([]byte(config)) {
+			return fmt.Errorf("mcpConfig is not valid JSON")
+		}
+	} else if config != "" {
+		if !filepath.IsAbs(config) {
+			return fmt.Errorf("mcpConfig must be JSON or an absolute path: %q", config)
+		}
+		if info, err := os.Stat(config); err != nil || info.IsDir() {
+			return fmt.Errorf("mcpConfig: %q is not a file", config)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordingRequest starts or stops recording a session
+type RecordingRequest struct {
+	Enabled bool `json:"enabled"`
+	Input   bool `json:"input"`
+}
+
+// InputRequest types into a session. Text is sent first, then the raw
+// bytes, then the named keys.
+type InputRequest struct {
+	Text    string   `json:"text"`
+	Raw     []byte   `json:"raw"`     // base64 encoded in JSON
+	Keys    []string `json:"keys"`    // named keys such as "Enter", "Esc" or "Ctrl-C"
+	Wait    bool     `json:"wait"`    // wait for output to settle before responding
+	Settle  int      `json:"settle"`  // milliseconds of quiet that count as settled
+	Timeout int      `json:"timeout"` // longest wait in milliseconds
+}
+
+// SignalRequest delivers a signal such as "SIGINT" to a session
+type SignalRequest struct {
+	Signal string `json:"signal"`
 }
 
 // KillRequest represents a session kill request
 type KillRequest struct {
 	SessionID string `json:"sessionId"`
+	By        string `json:"by"` // who is killing the session, "user" if empty
 }
 
 // NewSession creates a new session with defaults
@@ -40,21 +268,52 @@ func NewSession(name, path, branch string) *Session {
 		Name:     name,
 		Path:     path,
 		Branch:   branch,
-		Status:   "starting",
+		Status:   StatusStarting,
 		Created:  time.Now(),
 		LastSeen: time.Now(),
 	}
 }
 
+// Clone returns a copy of the session that later changes to it do not
+// affect
+func (s *Session) Clone() *Session {
+	clone := *s
+	clone.Command = append([]string(nil), s.Command...)
+	clone.Events = append([]Event(nil), s.Events...)
+	return &clone
+}
+
 // UpdateLastSeen updates the last seen timestamp
 func (s *Session) UpdateLastSeen() {
 	s.LastSeen = time.Now()
 }
 
-// SetStatus updates the session status
-func (s *Session) SetStatus(status string) {
-	s.Status = status
-	s.UpdateLastSeen()
+// SetSize records the current terminal window size
+func (s *Session) SetSize(rows, cols uint16) {
+	s.Rows = rows
+	s.Cols = cols
+}
+
+// SetActivity records the session's activity state and when it began
+func (s *Session) SetActivity(activity string, since, lastOutput time.Time) {
+	s.Activity = activity
+	s.ActivitySince = since
+	s.LastOutput = lastOutput
+}
+
+// SetUsage records the resources used by the session's processes
+func (s *Session) SetUsage(cpuPercent float64, memoryRSS int64, processes int) {
+	s.CPUPercent = cpuPercent
+	s.MemoryRSS = memoryRSS
+	s.Processes = processes
+}
+
+// AddEvent records an event, dropping the oldest beyond maxEvents
+func (s *Session) AddEvent(kind, message string) {
+	s.Events = append(s.Events, Event{Time: time.Now(), Type: kind, Message: message})
+	if len(s.Events) > maxEvents {
+		s.Events = append([]Event(nil), s.Events[len(s.Events)-maxEvents:]...)
+	}
 }
 
 // generateSessionID generates a unique session ID
@@ -65,4 +324,4 @@ func generateSessionID() string {
 		return fmt.Sprintf("session-%d", time.Now().UnixNano())
 	}
 	return hex.EncodeToString(bytes)
-}
\ No newline at end of file
+}
@@ -0,0 +1,163 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Session lifecycle states
+const (
+	StatusStarting = "starting" // the agent is being launched
+	StatusRunning  = "running"
+	StatusPaused   = "paused"   // stopped with SIGSTOP, see PTYSession.Pause
+	StatusExited   = "exited"   // the agent exited by itself, see ExitCode
+	StatusFailed   = "failed"   // the agent could not be run, see Reason
+	StatusKilled   = "killed"   // the agent was killed, see KilledBy
+	StatusDetached = "detached" // the server restarted and lost the agent, see Reason
+)
+
+// ErrInvalidTransition is returned for a state change the lifecycle does
+// not allow
+var ErrInvalidTransition = errors.New("invalid session state change")
+
+// transitions lists the states each state may move to. Finished sessions
+// may only be launched again.
+var transitions = map[string][]string{
+	StatusStarting: {StatusRunning, StatusFailed, StatusKilled},
+	StatusRunning:  {StatusPaused, StatusExited, StatusFailed, StatusKilled},
+	StatusPaused:   {StatusRunning, StatusExited, StatusFailed, StatusKilled},
+	StatusExited:   {StatusStarting},
+	StatusFailed:   {StatusStarting},
+	StatusKilled:   {StatusStarting},
+	StatusDetached: {StatusStarting},
+}
+
+// Finished reports whether the session's agent has stopped for good
+func (s *Session) Finished() bool {
+	switch s.Status {
+	case StatusExited, StatusFailed, StatusKilled, StatusDetached:
+		return true
+	}
+	return false
+}
+
+// Transition moves the session to a new state, recording it as an event
+func (s *Session) Transition(status, message string) error {
+	if !contains(transitions[s.Status], status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, s.Status, status)
+	}
+	s.Status = status
+	s.UpdateLastSeen()
+	s.AddEvent("lifecycle", message)
+	return nil
+}
+
+// Relaunching moves a finished session back to starting, clearing how its
+// last run ended
+func (s *Session) Relaunching() error {
+	if err := s.Transition(StatusStarting, "relaunching"); err != nil {
+		return err
+	}
+	s.ExitCode = nil
+	s.ExitSignal = ""
+	s.Reason = ""
+	s.KilledBy = ""
+	s.Ended = nil
+	s.Ready = false
+	return nil
+}
+
+// Started records that the agent is running with the given PID
+func (s *Session) Started(pid int) error {
+	if err := s.Transition(StatusRunning, fmt.Sprintf("started with PID %d", pid)); err != nil {
+		return err
+	}
+	s.PID = pid
+	now := time.Now()
+	s.Launched = &now
+	s.Launches++
+	return nil
+}
+
+// Adopted records that the agent, launched by an earlier run of the
+// manager, is running with the given PID. The session may be as it was
+// when the agent was launched, or as the store last saw it, already
+// running the agent.
+func (s *Session) Adopted(pid int, launched time.Time) error {
+	message := fmt.Sprintf("adopted with PID %d", pid)
+	if s.PID == pid && s.Status != StatusStarting {
+		s.Status = StatusRunning
+		s.Launched = &launched
+		s.UpdateLastSeen()
+		s.AddEvent("lifecycle", message)
+		return nil
+	}
+	if err := s.Transition(StatusRunning, message); err != nil {
+		return err
+	}
+	s.PID = pid
+	s.Launched = &launched
+	s.Launches++
+	return nil
+}
+
+// Exited records that the agent ended by itself, with an exit code or, if
+// a signal killed it, the signal's name
+func (s *Session) Exited(code int, signal string) error {
+	message := fmt.Sprintf("exited with code %d", code)
+	if signal != "" {
+		message = "killed by " + signal
+	}
+	if err := s.Transition(StatusExited, message); err != nil {
+		return err
+	}
+	s.setExit(code, signal)
+	return nil
+}
+
+// Detached records that a restarted server could not take the agent back,
+// and why. Unlike the other ways a run ends it applies to a session in any
+// state, as it was last recorded.
+func (s *Session) Detached(reason string) {
+	s.Status = StatusDetached
+	s.Reason = reason
+	s.UpdateLastSeen()
+	s.AddEvent("lifecycle", "detached: "+reason)
+}
+
+// Failed records that the agent could not be run
+func (s *Session) Failed(reason string) error {
+	if err := s.Transition(StatusFailed, "failed: "+reason); err != nil {
+		return err
+	}
+	s.Reason = reason
+	now := time.Now()
+	s.Ended = &now
+	return nil
+}
+
+// Killed records that the agent is being killed, and who asked for it
+func (s *Session) Killed(by string) error {
+	if err := s.Transition(StatusKilled, "killed by "+by); err != nil {
+		return err
+	}
+	s.KilledBy = by
+	now := time.Now()
+	s.Ended = &now
+	return nil
+}
+
+// RecordExit notes how a killed agent's process ended
+func (s *Session) RecordExit(code int, signal string) {
+	s.setExit(code, signal)
+}
+
+func (s *Session) setExit(code int, signal string) {
+	s.ExitCode = &code
+	s.ExitSignal = signal
+	if s.Ended == nil {
+		now := time.Now()
+		s.Ended = &now
+	}
+}
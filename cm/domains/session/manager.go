@@ -1,60 +0,0 @@
-package session
-
-import (
-	"sync"
-)
-
-// Manager manages all active sessions
-type Manager struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
-}
-
-// NewManager creates a new session manager
-func NewManager() *Manager {
-	return &Manager{
-		sessions: make(map[string]*Session),
-	}
-}
-
-// Add adds a new session to the manager
-func (m *Manager) Add(session *Session) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.sessions[session.ID] = session
-}
-
-// Get retrieves a session by ID
-func (m *Manager) Get(id string) (*Session, bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	session, exists := m.sessions[id]
-	return session, exists
-}
-
-// Remove removes a session from the manager
-func (m *Manager) Remove(id string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	delete(m.sessions, id)
-}
-
-// List returns all sessions
-func (m *Manager) List() []*Session {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	
-	sessions := make([]*Session, 0, len(m.sessions))
-	for _, session := range m.sessions {
-		sessions = append(sessions, session)
-	}
-	return sessions
-}
-
-// Count returns the number of active sessions
-func (m *Manager) Count() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return len(m.sessions)
-}
-
@@ -0,0 +1,106 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Restart policies
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure" // restart when the agent exits non-zero or is killed by a signal
+	RestartAlways    = "always"     // restart whenever the agent exits by itself
+)
+
+// RestartPolicies lists the restart policies a session may have
+var RestartPolicies = []string{RestartNever, RestartOnFailure, RestartAlways}
+
+// Restart backoff defaults, in seconds
+const (
+	DefaultBackoff    = 1
+	DefaultMaxBackoff = 60
+)
+
+// stableRun is how long an agent must stay up for its earlier restarts to
+// be forgotten
+const stableRun = 5 * time.Minute
+
+// RestartPolicy decides whether a session's agent is started again when
+// it exits by itself. An agent that is killed is never restarted.
+type RestartPolicy struct {
+	Policy     string  `json:"policy"`               // see RestartPolicies; never by default
+	MaxRetries int     `json:"maxRetries,omitempty"` // consecutive restarts allowed, 0 for no limit
+	Backoff    float64 `json:"backoff,omitempty"`    // seconds before the first restart, doubling for each one after
+	MaxBackoff float64 `json:"maxBackoff,omitempty"` // seconds the delay grows to at most
+}
+
+// IsZero reports whether the agent is never restarted
+func (p RestartPolicy) IsZero() bool {
+	return p.Policy == "" || p.Policy == RestartNever
+}
+
+// Validate checks the policy
+func (p RestartPolicy) Validate() error {
+	switch {
+	case p.Policy != "" && !contains(RestartPolicies, p.Policy):
+		return fmt.Errorf("policy must be one of %s", strings.Join(RestartPolicies, ", "))
+	case p.MaxRetries < 0:
+		return fmt.Errorf("maxRetries must not be negative")
+	case p.Backoff < 0:
+		return fmt.Errorf("backoff must not be negative")
+	case p.MaxBackoff < 0:
+		return fmt.Errorf("maxBackoff must not be negative")
+	}
+	return nil
+}
+
+// Delay returns how long to wait before the given restart, counting from 1
+func (p RestartPolicy) Delay(restart int) time.Duration {
+	backoff, limit := p.Backoff, p.MaxBackoff
+	if backoff == 0 {
+		backoff = DefaultBackoff
+	}
+	if limit == 0 {
+		limit = DefaultMaxBackoff
+	}
+	delay := backoff
+	for i := 1; i < restart && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return time.Duration(delay * float64(time.Second))
+}
+
+// PlanRestart applies the session's restart policy once its agent has
+// finished, recording the decision as an event. It returns how long to
+// wait before starting the agent again, or false if it stays finished.
+func (s *Session) PlanRestart() (time.Duration, bool) {
+	if s.Restart == nil || s.Restart.IsZero() || s.Status != StatusExited {
+		return 0, false
+	}
+	failed := s.ExitSignal != "" || (s.ExitCode != nil && *s.ExitCode != 0)
+	if s.Restart.Policy == RestartOnFailure && !failed {
+		return 0, false
+	}
+
+	// A run that stayed up clears the restarts before it
+	if s.Launched != nil && s.Ended != nil && s.Ended.Sub(*s.Launched) >= stableRun {
+		s.Restarts = 0
+	}
+	if s.Restart.MaxRetries > 0 && s.Restarts >= s.Restart.MaxRetries {
+		s.AddEvent("restart", fmt.Sprintf("not restarting after %d restarts", s.Restarts))
+		return 0, false
+	}
+
+	s.Restarts++
+	delay := s.Restart.Delay(s.Restarts)
+	message := fmt.Sprintf("restart %d in %s", s.Restarts, delay)
+	if s.Restart.MaxRetries > 0 {
+		message = fmt.Sprintf("restart %d of %d in %s", s.Restarts, s.Restart.MaxRetries, delay)
+	}
+	s.AddEvent("restart", message)
+	return delay, true
+}
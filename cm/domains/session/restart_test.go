@@ -0,0 +1,79 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartDelay(t *testing.T) {
+	policy := RestartPolicy{Policy: RestartAlways, Backoff: 2, MaxBackoff: 10}
+	for restart, want := range map[int]time.Duration{1: 2 * time.Second, 2: 4 * time.Second, 3: 8 * time.Second, 4: 10 * time.Second, 40: 10 * time.Second} {
+		if got := policy.Delay(restart); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", restart, got, want)
+		}
+	}
+	if got := (RestartPolicy{}).Delay(1); got != DefaultBackoff*time.Second {
+		t.Errorf("default Delay(1) = %v", got)
+	}
+}
+
+func TestPlanRestart(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *RestartPolicy
+		code     int
+		signal   string
+		restarts int
+		want     bool
+	}{
+		{"no policy", nil, 1, "", 0, false},
+		{"never", &RestartPolicy{Policy: RestartNever}, 1, "", 0, false},
+		{"on-failure after success", &RestartPolicy{Policy: RestartOnFailure}, 0, "", 0, false},
+		{"on-failure after error", &RestartPolicy{Policy: RestartOnFailure}, 2, "", 0, true},
+		{"on-failure after signal", &RestartPolicy{Policy: RestartOnFailure}, -1, "SIGKILL", 0, true},
+		{"always after success", &RestartPolicy{Policy: RestartAlways}, 0, "", 0, true},
+		{"retries left", &RestartPolicy{Policy: RestartAlways, MaxRetries: 3}, 1, "", 2, true},
+		{"retries used up", &RestartPolicy{Policy: RestartAlways, MaxRetries: 3}, 1, "", 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSession("test", "/tmp", "main")
+			s.Restart = tt.policy
+			s.Restarts = tt.restarts
+			s.Started(100)
+			s.Exited(tt.code, tt.signal)
+
+			_, got := s.PlanRestart()
+			if got != tt.want {
+				t.Fatalf("PlanRestart = %v, want %v", got, tt.want)
+			}
+			if got && (s.Restarts != tt.restarts+1 || s.Events[len(s.Events)-1].Type != "restart") {
+				t.Errorf("restarts = %d, events %+v", s.Restarts, s.Events)
+			}
+		})
+	}
+}
+
+func TestPlanRestartAfterStableRun(t *testing.T) {
+	s := NewSession("test", "/tmp", "main")
+	s.Restart = &RestartPolicy{Policy: RestartAlways, MaxRetries: 1}
+	s.Restarts = 1
+	s.Started(100)
+	launched := time.Now().Add(-time.Hour)
+	s.Launched = &launched
+	s.Exited(0, "")
+
+	if _, ok := s.PlanRestart(); !ok || s.Restarts != 1 {
+		t.Errorf("PlanRestart = %v with %d restarts, want a first restart", ok, s.Restarts)
+	}
+}
+
+func TestPlanRestartKilled(t *testing.T) {
+	s := NewSession("test", "/tmp", "main")
+	s.Restart = &RestartPolicy{Policy: RestartAlways}
+	s.Started(100)
+	s.Killed("user")
+	if _, ok := s.PlanRestart(); ok {
+		t.Error("a killed agent should not be restarted")
+	}
+}
@@ -0,0 +1,130 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Record is a session as the store keeps it
+type Record struct {
+	Session json.RawMessage   `json:"session"`
+	Secrets map[string]string `json:"secrets,omitempty"` // values of secret variables, which the session masks
+}
+
+// NewRecord captures a session's current state. The caller must keep the
+// session from changing meanwhile.
+func NewRecord(s *Session) (Record, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return Record{}, err
+	}
+	record := Record{Session: data}
+	if s.Environment != nil {
+		for _, v := range s.Environment.Env {
+			if v.Secret {
+				if record.Secrets == nil {
+					record.Secrets = make(map[string]string)
+				}
+				record.Secrets[v.Name] = v.Value
+			}
+		}
+	}
+	return record, nil
+}
+
+// Restore returns the session the record was made from
+func (r Record) Restore() (*Session, error) {
+	s := &Session{}
+	if err := json.Unmarshal(r.Session, s); err != nil {
+		return nil, err
+	}
+	if s.Environment != nil {
+		for i, v := range s.Environment.Env {
+			if v.Secret {
+				s.Environment.Env[i].Value = r.Secrets[v.Name]
+			}
+		}
+	}
+	return s, nil
+}
+
+// Store keeps session records in a JSON file, so that the server knows
+// its sessions again after a restart. The file may hold secrets, so only
+// its owner can read it.
+type Store struct {
+	path string
+	mu   sync.Mutex
+	last []byte // what was last written, to skip unchanged saves
+}
+
+// NewStore creates a store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Load reads the stored records. A store that has never been saved holds
+// none.
+func (st *Store) Load() ([]Record, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	data, err := os.ReadFile(st.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	st.last = data
+	return records, nil
+}
+
+// Save replaces the stored records. The file is replaced atomically, so a
+// crash leaves either the old records or the new ones.
+func (st *Store) Save(records []Record) error {
+	if records == nil {
+		records = []Record{}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if bytes.Equal(data, st.last) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(st.path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(st.path), filepath.Base(st.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), st.path); err != nil {
+		return err
+	}
+	st.last = data
+	return nil
+}
@@ -0,0 +1,89 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "sessions.json")
+	store := NewStore(path)
+
+	records, err := store.Load()
+	if err != nil || records != nil {
+		t.Fatalf("Load of a missing store = %v, %v", records, err)
+	}
+
+	s := NewSession("test", "/tmp", "main")
+	s.ID = "session_1"
+	s.Environment = &EnvOptions{Env: []EnvVar{
+		{Name: "MODE", Value: "debug"},
+		{Name: "TOKEN", Value: "hunter2", Secret: true},
+	}}
+	s.Started(100)
+	record, err := NewRecord(s)
+	if err != nil {
+		t.Fatalf("NewRecord: %v", err)
+	}
+	if err := store.Save([]Record{record}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("stored file: %v, %v", info, err)
+	}
+
+	records, err = NewStore(path).Load()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Load = %v, %v", records, err)
+	}
+	restored, err := records[0].Restore()
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.ID != "session_1" || restored.Status != StatusRunning || restored.PID != 100 {
+		t.Errorf("restored %+v", restored)
+	}
+	if env := restored.Environment.Env; env[0].Value != "debug" || env[1].Value != "hunter2" {
+		t.Errorf("restored environment %+v", env)
+	}
+}
+
+func TestStoreSkipsUnchangedSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store := NewStore(path)
+	if err := store.Save(nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// A save that changes nothing leaves the file alone
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(path, old, old)
+	if err := store.Save(nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if info, _ := os.Stat(path); !info.ModTime().Equal(old) {
+		t.Errorf("unchanged save rewrote the file")
+	}
+}
+
+func TestDetachedAndAdopted(t *testing.T) {
+	s := NewSession("test", "/tmp", "main")
+	s.Started(100)
+
+	// A stored record of the agent a holder still runs is taken as it is
+	launched := time.Now()
+	if err := s.Adopted(100, launched); err != nil || s.Status != StatusRunning || s.Launches != 1 {
+		t.Fatalf("Adopted = %v, status %s, launches %d", err, s.Status, s.Launches)
+	}
+
+	s.Detached("the server restarted")
+	if !s.Finished() || s.Reason != "the server restarted" {
+		t.Fatalf("status %s, reason %q", s.Status, s.Reason)
+	}
+	if err := s.Relaunching(); err != nil || s.Status != StatusStarting || s.Reason != "" {
+		t.Errorf("Relaunching = %v, status %s, reason %q", err, s.Status, s.Reason)
+	}
+}
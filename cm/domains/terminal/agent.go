@@ -0,0 +1,147 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+
+	"github.com/user/claude-manager/domains/limits"
+	"github.com/user/claude-manager/domains/process"
+)
+
+// Agent is a started agent: its process and the terminal it runs on
+type Agent interface {
+	io.ReadWriter // output from and input to the terminal
+
+	// Resize sets the terminal's window size
+	Resize(rows, cols uint16) error
+	// PID returns the agent's process ID, which also leads its process
+	// group and session
+	PID() int
+	// Wait blocks until the agent has exited, tears down anything it left
+	// running, and returns its exit code or, if a signal killed it, the
+	// signal's name. ErrDetached means the agent is still running.
+	Wait() (code int, signal string, err error)
+	// Kill terminates the agent and everything it started, giving them
+	// grace to exit before they are killed outright
+	Kill(grace time.Duration) error
+	// Close releases the terminal
+	Close() error
+}
+
+// ErrDetached is returned by Agent.Wait once an agent kept by a holder
+// has been detached from this process
+var ErrDetached = errors.New("agent detached")
+
+// Launcher starts a session's agent at the given window size, confined to
+// the group's limits
+type Launcher func(ps *PTYSession, spec LaunchSpec, size Size, group *limits.Group) (Agent, error)
+
+// Launch starts agents. By default they run as children of this process;
+// a holder may keep them instead so that they outlive it.
+var Launch Launcher = StartLocal
+
+// StartLocal starts an agent as a child of this process
+func StartLocal(ps *PTYSession, spec LaunchSpec, size Size, group *limits.Group) (Agent, error) {
+	cmd := exec.Command(spec.Command, spec.Args...)
+	cmd.Dir = spec.Dir
+	cmd.Env = spec.Env
+	// Give the agent its own session and process group, with the PTY as
+	// its controlling terminal, so teardown can reach every process it
+	// starts
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	group.Configure(cmd)
+
+	ptyFile, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: size.Rows, Cols: size.Cols})
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalAgent(ptyFile, cmd), nil
+}
+
+// localAgent is an agent running as a child of this process
+type localAgent struct {
+	pty *os.File
+	cmd *exec.Cmd
+
+	teardown sync.Mutex // held while the agent's processes are killed
+	reaped   bool       // the agent's PID may have been reused
+}
+
+// NewLocalAgent wraps a command already started on a PTY
+func NewLocalAgent(ptyFile *os.File, cmd *exec.Cmd) Agent {
+	return &localAgent{pty: ptyFile, cmd: cmd}
+}
+
+func (a *localAgent) Read(p []byte) (int, error)  { return a.pty.Read(p) }
+func (a *localAgent) Write(p []byte) (int, error) { return a.pty.Write(p) }
+func (a *localAgent) PID() int                    { return a.cmd.Process.Pid }
+func (a *localAgent) Close() error                { return a.pty.Close() }
+
+func (a *localAgent) Resize(rows, cols uint16) error {
+	return pty.Setsize(a.pty, &pty.Winsize{Rows: rows, Cols: cols})
+}
+
+func (a *localAgent) Wait() (int, string, error) {
+	// Tear down before reaping, so the leader's PID cannot be reused by
+	// then
+	process.WaitExited(a.PID())
+	a.teardown.Lock()
+	err := process.KillTree(a.PID(), process.DefaultGrace)
+	a.cmd.Wait()
+	a.reaped = true
+	a.teardown.Unlock()
+
+	code, signal := ExitStatus(a.cmd.ProcessState)
+	return code, signal, err
+}
+
+func (a *localAgent) Kill(grace time.Duration) error {
+	a.teardown.Lock()
+	defer a.teardown.Unlock()
+	if a.reaped {
+		return nil
+	}
+	return process.KillTree(a.PID(), grace)
+}
+
+// ExitStatus returns a process's exit code, or -1 and the signal's name if
+// a signal killed it
+func ExitStatus(state *os.ProcessState) (int, string) {
+	if state == nil {
+		return -1, ""
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return -1, signalName(status.Signal())
+	}
+	return state.ExitCode(), ""
+}
+
+// signalName returns a signal's SIG name
+func signalName(sig syscall.Signal) string {
+	for name, known := range Signals {
+		if known == sig {
+			return name
+		}
+	}
+	switch sig {
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGSEGV:
+		return "SIGSEGV"
+	case syscall.SIGABRT:
+		return "SIGABRT"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGBUS:
+		return "SIGBUS"
+	}
+	return fmt.Sprintf("signal %d", int(sig))
+}
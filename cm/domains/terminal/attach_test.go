@@ -0,0 +1,82 @@
+package terminal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+func TestAttachRepaintOrResume(t *testing.T) {
+	ps, err := NewPTYSession("test", session.NewSession("test", "/tmp", "main"))
+	if err != nil {
+		t.Fatalf("NewPTYSession: %v", err)
+	}
+	ps.BroadcastToClients([]byte("\x1b[2J\x1b[Hhello\r\nworld"))
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		opts := AttachOptions{}
+		if r.URL.Query().Has("offset") {
+			opts.Offset, opts.Resume = 14, true
+		}
+		ps.AddClient(conn, opts)
+	}))
+	defer server.Close()
+
+	// firstFrame dials the session and returns the first non-roles message
+	firstFrame := func(query string) (int, []byte) {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+query, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			messageType, frame, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if messageType == websocket.TextMessage && strings.Contains(string(frame), `"type":"roles"`) {
+				continue
+			}
+			return messageType, frame
+		}
+	}
+
+	// A new client gets a repaint ending at the live offset
+	messageType, frame := firstFrame("")
+	var repaint RepaintMessage
+	if messageType != websocket.TextMessage || json.Unmarshal(frame, &repaint) != nil || repaint.Type != MessageRepaint {
+		t.Fatalf("first message = %q, want a repaint", frame)
+	}
+	if repaint.Offset != 19 || !strings.Contains(repaint.Data, "hello") || !strings.Contains(repaint.Data, "world") {
+		t.Errorf("repaint = %+v", repaint)
+	}
+
+	// A resuming client gets only the output it missed
+	messageType, frame = firstFrame("?offset=14")
+	if messageType != websocket.BinaryMessage {
+		t.Fatalf("first message = %q, want output", frame)
+	}
+	offset, data, _ := ParseOutputFrame(frame)
+	if offset != 14 || string(data) != "world" {
+		t.Errorf("replay = %q@%d, want %q@14", data, offset, "world")
+	}
+
+	if got := ps.ScreenText(); !strings.HasPrefix(got, "hello\nworld\n") {
+		t.Errorf("ScreenText() = %q", got)
+	}
+}
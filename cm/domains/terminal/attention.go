@@ -0,0 +1,69 @@
+package terminal
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user/claude-manager/domains/attention"
+)
+
+// UpdateAttention re-evaluates whether the session is working, idle or
+// waiting for the user, records the result on the Session and reports
+// whether it changed. A paused session keeps the state it had, so that it
+// is not mistaken for an idle one.
+func (ps *PTYSession) UpdateAttention(now time.Time) bool {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	if ps.paused {
+		return false
+	}
+
+	row, _, _ := ps.screen.Cursor()
+	view := attention.View{
+		Lines:     strings.Split(ps.screen.Text(), "\n"),
+		CursorRow: row,
+	}
+	changed := ps.attention.Evaluate(view, now)
+
+	state, since := ps.attention.State()
+	ps.Session.SetActivity(string(state), since, ps.attention.LastOutput())
+	if changed {
+		defer ps.publish(Event{Type: EventActivity})
+	}
+
+	// The agent is ready once its ready pattern appears or, without one,
+	// once it first stops working
+	if !ps.Session.Ready {
+		if ps.ready != nil {
+			ps.Session.Ready = ps.ready.MatchString(strings.Join(view.Lines, "\n"))
+		} else {
+			ps.Session.Ready = state != attention.Working
+		}
+	}
+	return changed
+}
+
+// SetDetection sets the patterns that show the session's agent has started,
+// is waiting on a question or is busy. Nil prompt or busy patterns keep the
+// defaults.
+func (ps *PTYSession) SetDetection(ready *regexp.Regexp, prompt, busy []*regexp.Regexp) {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	ps.ready = ready
+	if prompt != nil {
+		ps.attention.PromptPatterns = prompt
+	}
+	if busy != nil {
+		ps.attention.BusyPatterns = busy
+	}
+}
+
+// Attention returns the session's current activity state
+func (ps *PTYSession) Attention() attention.State {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	state, _ := ps.attention.State()
+	return state
+}
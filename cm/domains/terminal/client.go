@@ -0,0 +1,143 @@
+package terminal
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ClientQueueSize is the number of frames buffered per client before it is
+// considered to be lagging behind the session
+var ClientQueueSize = 256
+
+// clientWriteTimeout bounds a single write so that a stalled connection is
+// closed instead of holding its writer forever
+const clientWriteTimeout = 10 * time.Second
+
+// MessageOverrun tells a lagging client that output was skipped
+const MessageOverrun = "overrun"
+
+// OverrunMessage reports output a lagging client never received. Live
+// output resumes at Offset; the Missed bytes before it were dropped.
+type OverrunMessage struct {
+	Type   string `json:"type"`
+	Missed int64  `json:"missed"`
+	Offset int64  `json:"offset"`
+}
+
+// outbound is a frame waiting in a client's send queue
+type outbound struct {
+	messageType int
+	data        []byte
+}
+
+// Client is a WebSocket connection attached to a PTY session. Every write
+// to the connection goes through the client's bounded send queue and is
+// performed by its own writer goroutine, so a slow client can never block
+// the PTY reader or other viewers.
+type Client struct {
+	ID   string
+	Name string
+	Conn *websocket.Conn
+
+	send      chan outbound
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// Guarded by PTYSession.Mu
+	role      string    // RoleDriver or RoleViewer
+	size      Size      // window size reported by the client
+	lagging   bool      // output is being skipped until the queue drains
+	missed    int64     // bytes skipped while lagging
+	connected time.Time // when the client attached
+}
+
+// newClient creates a client and starts its writer
+func newClient(conn *websocket.Conn, name string) *Client {
+	c := &Client{
+		ID:        generateClientID(),
+		Name:      name,
+		Conn:      conn,
+		send:      make(chan outbound, ClientQueueSize),
+		done:      make(chan struct{}),
+		connected: time.Now(),
+	}
+	go c.writePump()
+	return c
+}
+
+// writePump serializes all writes to the connection
+func (c *Client) writePump() {
+	for {
+		select {
+		case msg := <-c.send:
+			c.Conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+			if err := c.Conn.WriteMessage(msg.messageType, msg.data); err != nil {
+				// Closing the connection also ends the handler's read loop,
+				// which removes the client from its session
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// enqueue queues a frame without blocking. It returns false if the queue
+// is full.
+func (c *Client) enqueue(messageType int, data []byte) bool {
+	select {
+	case c.send <- outbound{messageType: messageType, data: data}:
+		return true
+	default:
+		return false
+	}
+}
+
+// SendJSON queues a JSON control message for the client
+func (c *Client) SendJSON(v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	return c.enqueue(websocket.TextMessage, data)
+}
+
+// sendOutput queues an output frame starting at offset. A client whose
+// queue is full is fast-forwarded: output is skipped until the queue has
+// drained to half its size, then an overrun notice is sent ahead of the
+// next frame. Must be called with PTYSession.Mu held.
+func (c *Client) sendOutput(offset int64, frame []byte) {
+	length := int64(len(frame) - OutputHeaderSize)
+
+	if c.lagging {
+		if len(c.send) > cap(c.send)/2 {
+			c.missed += length
+			return
+		}
+		if !c.SendJSON(OverrunMessage{Type: MessageOverrun, Missed: c.missed, Offset: offset}) {
+			c.missed += length
+			return
+		}
+		c.lagging = false
+		c.missed = 0
+	}
+
+	if !c.enqueue(websocket.BinaryMessage, frame) {
+		c.lagging = true
+		c.missed += length
+	}
+}
+
+// Close stops the writer and closes the connection
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.Conn != nil {
+			c.Conn.Close()
+		}
+	})
+}
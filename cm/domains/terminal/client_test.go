@@ -0,0 +1,61 @@
+package terminal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/user/claude-manager/domains/attention"
+	"github.com/user/claude-manager/domains/screen"
+)
+
+func TestSlowClientFastForward(t *testing.T) {
+	// A client whose writer never runs stands in for a stalled browser
+	slow := &Client{send: make(chan outbound, 4), done: make(chan struct{})}
+	ps := &PTYSession{
+		Clients:    map[*websocket.Conn]*Client{nil: slow},
+		scrollback: NewScrollback(1024),
+		attention:  attention.NewDetector(time.Now()),
+		screen:     screen.New(DefaultRows, DefaultCols),
+	}
+
+	// Broadcasting past the queue size must not block
+	for i := 0; i < 10; i++ {
+		ps.BroadcastToClients([]byte("0123456789"))
+	}
+	if !slow.lagging {
+		t.Fatal("client with a full queue should be lagging")
+	}
+	if slow.missed != 60 {
+		t.Errorf("missed = %d, want 60", slow.missed)
+	}
+
+	// Once the queue drains the client gets a notice, then live output
+	for len(slow.send) > 0 {
+		<-slow.send
+	}
+	ps.BroadcastToClients([]byte("live"))
+
+	notice := <-slow.send
+	if notice.messageType != websocket.TextMessage {
+		t.Fatalf("first frame after draining should be an overrun notice")
+	}
+	var msg OverrunMessage
+	if err := json.Unmarshal(notice.data, &msg); err != nil {
+		t.Fatalf("unmarshal notice: %v", err)
+	}
+	if msg.Type != MessageOverrun || msg.Missed != 60 || msg.Offset != 100 {
+		t.Errorf("notice = %+v, want 60 bytes missed resuming at 100", msg)
+	}
+
+	frame := <-slow.send
+	offset, data, err := ParseOutputFrame(frame.data)
+	if err != nil || offset != 100 || string(data) != "live" {
+		t.Errorf("frame = %q@%d (%v), want %q@100", data, offset, err, "live")
+	}
+	if slow.lagging {
+		t.Error("client should have caught up")
+	}
+}
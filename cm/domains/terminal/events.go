@@ -0,0 +1,158 @@
+package terminal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+// EventType identifies what happened to a session
+type EventType string
+
+// Session events published by the registry
+const (
+	EventCreated        EventType = "created"         // the session was registered
+	EventStatus         EventType = "status"          // its lifecycle status changed
+	EventClientAttached EventType = "client_attached" // a client attached to its terminal
+	EventClientLeft     EventType = "client_left"     // a client went away
+	EventActivity       EventType = "activity"        // it started or stopped working or waiting for input
+	EventBranch         EventType = "branch"          // a different branch was checked out in it
+	EventExited         EventType = "exited"          // its agent's run ended and was torn down
+	EventLimitBreach    EventType = "limit_breach"    // it ran into one of its resource limits
+	EventRemoved        EventType = "removed"         // the session was dismissed
+)
+
+// DefaultHistory is how many recent events a bus retains for subscribers
+// that resume, see Bus.Since
+const DefaultHistory = 1024
+
+// Event is something that happened to a session
+type Event struct {
+	ID        uint64    `json:"id"` // increases with every event published
+	Type      EventType `json:"type"`
+	SessionID string    `json:"session_id"`
+	Time      time.Time `json:"time"`
+
+	Status     string `json:"status,omitempty"`      // the status after the change
+	Previous   string `json:"previous,omitempty"`    // the status before a status change
+	Clients    int    `json:"clients"`               // clients attached after the event
+	ExitCode   *int   `json:"exit_code,omitempty"`   // how an exited agent ended
+	ExitSignal string `json:"exit_signal,omitempty"` // the signal that killed it, if one did
+	Message    string `json:"message,omitempty"`     // the limit a breach ran into
+
+	// Session is the session as it was just after the event, except for
+	// removals
+	Session *session.Session `json:"session,omitempty"`
+}
+
+// Bus delivers session events to subscribers in this process
+type Bus struct {
+	mu          sync.Mutex
+	last        uint64
+	subscribers map[chan Event]struct{}
+	history     []Event // the most recent events, oldest first
+	keep        int     // how many events history holds at most
+}
+
+// NewBus creates an event bus with no subscribers that retains the last
+// keep events
+func NewBus(keep int) *Bus {
+	return &Bus{subscribers: make(map[chan Event]struct{}), keep: keep}
+}
+
+// Subscribe returns a channel of the events published from now on and a
+// function that ends the subscription. Publishing never waits for a
+// subscriber, so one that falls buffer events behind is dropped: its
+// channel is closed.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, subscribed := b.subscribers[ch]; subscribed {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish numbers an event, stamps it with the time and delivers it to
+// every subscriber
+func (b *Bus) Publish(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.last++
+	event.ID = b.last
+	event.Time = time.Now()
+	if b.keep > 0 {
+		if len(b.history) == b.keep {
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+		b.history = append(b.history, event)
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return event
+}
+
+// Last returns the ID of the last event published, or 0 if there has
+// been none
+func (b *Bus) Last() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
+// Since returns the retained events published after the event with ID
+// last. It reports false if some of them are no longer retained, or if
+// last is not an event this bus published.
+func (b *Bus) Since(last uint64) ([]Event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if last > b.last {
+		return nil, false
+	}
+	missed := b.last - last
+	if missed > uint64(len(b.history)) {
+		return nil, false
+	}
+	return append([]Event(nil), b.history[len(b.history)-int(missed):]...), true
+}
+
+// publish sends an event about the session to the registry's subscribers,
+// if the session is registered. Must be called with Mu held.
+func (ps *PTYSession) publish(event Event) {
+	if ps.bus == nil {
+		return
+	}
+	event.SessionID = ps.ID
+	event.Clients = len(ps.Clients)
+	if event.Status == "" {
+		event.Status = ps.Session.Status
+	}
+	event.Session = ps.Session.Clone()
+	ps.bus.Publish(event)
+}
+
+// lifecycle applies a change to the session's lifecycle and publishes
+// the new status if it changed. Must be called with Mu held.
+func (ps *PTYSession) lifecycle(change func() error) error {
+	from := ps.Session.Status
+	err := change()
+	if ps.Session.Status != from {
+		ps.publish(Event{Type: EventStatus, Previous: from})
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package terminal
+
+import "testing"
+
+func TestBusDropsSlowSubscriber(t *testing.T) {
+	bus := NewBus(0)
+	slow, unsubscribeSlow := bus.Subscribe(1)
+	fast, unsubscribeFast := bus.Subscribe(4)
+	defer unsubscribeFast()
+
+	bus.Publish(Event{Type: EventCreated})
+	bus.Publish(Event{Type: EventRemoved})
+
+	if event, ok := <-slow; !ok || event.ID != 1 {
+		t.Fatalf("slow subscriber got %+v, %v", event, ok)
+	}
+	if _, ok := <-slow; ok {
+		t.Error("a subscriber that fell behind should be dropped")
+	}
+	unsubscribeSlow() // safe after being dropped
+
+	for _, id := range []uint64{1, 2} {
+		if event := <-fast; event.ID != id {
+			t.Errorf("fast subscriber got event %d, want %d", event.ID, id)
+		}
+	}
+}
+
+func TestBusSince(t *testing.T) {
+	bus := NewBus(3)
+	if events, ok := bus.Since(0); !ok || len(events) != 0 || bus.Last() != 0 {
+		t.Fatalf("Since(0) on a new bus = %v, %v", events, ok)
+	}
+	for i := 0; i < 5; i++ {
+		bus.Publish(Event{Type: EventStatus})
+	}
+
+	tests := []struct {
+		last uint64
+		want []uint64
+		ok   bool
+	}{
+		{5, nil, true},
+		{3, []uint64{4, 5}, true},
+		{2, []uint64{3, 4, 5}, true},
+		{1, nil, false}, // event 2 is no longer retained
+		{9, nil, false}, // not published by this bus
+	}
+	for _, tt := range tests {
+		events, ok := bus.Since(tt.last)
+		if ok != tt.ok || len(events) != len(tt.want) {
+			t.Errorf("Since(%d) = %v, %v; want %v, %v", tt.last, events, ok, tt.want, tt.ok)
+			continue
+		}
+		for i, event := range events {
+			if event.ID != tt.want[i] {
+				t.Errorf("Since(%d)[%d] = event %d, want %d", tt.last, i, event.ID, tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// namedKeys maps key names, lower-cased, to what a terminal sends for them
+var namedKeys = map[string]string{
+	"enter":     "\r",
+	"return":    "\r",
+	"tab":       "\t",
+	"shift-tab": "\x1b[Z",
+	"esc":       "\x1b",
+	"escape":    "\x1b",
+	"backspace": "\x7f",
+	"space":     " ",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+	"home":      "\x1b[H",
+	"end":       "\x1b[F",
+	"pageup":    "\x1b[5~",
+	"pagedown":  "\x1b[6~",
+	"insert":    "\x1b[2~",
+	"delete":    "\x1b[3~",
+}
+
+// KeySequence returns the bytes sent for a named key such as "Enter",
+// "Esc", "Up" or "Ctrl-C". Control keys may also be written "Ctrl+C",
+// "C-c" or "^C".
+func KeySequence(name string) ([]byte, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.ReplaceAll(key, "_", "-")
+	key = strings.ReplaceAll(key, "+", "-")
+	if seq, ok := namedKeys[key]; ok {
+		return []byte(seq), nil
+	}
+
+	for _, prefix := range []string{"ctrl-", "control-", "c-", "^"} {
+		rest, found := strings.CutPrefix(key, prefix)
+		if !found || len(rest) != 1 {
+			continue
+		}
+		switch c := rest[0]; {
+		case c >= 'a' && c <= 'z':
+			return []byte{c - 'a' + 1}, nil
+		case c >= '@' && c <= '_':
+			return []byte{c & 0x1f}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", name)
+}
@@ -0,0 +1,33 @@
+package terminal
+
+import (
+	"testing"
+)
+
+func TestKeySequence(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Enter", "\r"},
+		{"esc", "\x1b"},
+		{"Up", "\x1b[A"},
+		{"Shift+Tab", "\x1b[Z"},
+		{"Ctrl-C", "\x03"},
+		{"ctrl+d", "\x04"},
+		{"C-z", "\x1a"},
+		{"^[", "\x1b"},
+	}
+	for _, tt := range tests {
+		got, err := KeySequence(tt.name)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("KeySequence(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	for _, name := range []string{"", "Hyper", "Ctrl-CC"} {
+		if _, err := KeySequence(name); err == nil {
+			t.Errorf("KeySequence(%q) should fail", name)
+		}
+	}
+}
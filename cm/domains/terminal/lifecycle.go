@@ -0,0 +1,235 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/user/claude-manager/domains/limits"
+	"github.com/user/claude-manager/domains/process"
+	"github.com/user/claude-manager/domains/session"
+)
+
+// ErrRunning is returned when a session's agent must have finished first
+var ErrRunning = errors.New("session is still running")
+
+// ErrNotDetachable is returned for a session whose agent would not survive
+// this process
+var ErrNotDetachable = errors.New("session cannot be detached")
+
+// LaunchSpec is everything needed to start, or start again, a session's
+// agent
+type LaunchSpec struct {
+	Command string
+	Args    []string
+	Env     []string
+	Dir     string
+	Limits  session.Limits
+}
+
+// SetLaunch records how the session's agent is started
+func (ps *PTYSession) SetLaunch(spec LaunchSpec) {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	ps.launch = spec
+}
+
+// Start launches the session's agent on a new PTY at the size negotiated
+// so far. The session must be starting; Relaunch prepares a finished one.
+// A failure to launch marks the session failed.
+func (ps *PTYSession) Start() (Agent, error) {
+	ps.Mu.RLock()
+	spec := ps.launch
+	status := ps.Session.Status
+	ps.Mu.RUnlock()
+	if status != session.StatusStarting {
+		return nil, ErrRunning
+	}
+
+	// Confine the session to its resource limits
+	group, err := limits.Prepare(ps.ID, spec.Limits)
+	if err != nil {
+		return nil, ps.failed(fmt.Errorf("cannot apply limits: %w", err))
+	}
+
+	ps.SetLimits(group)
+
+	agent, err := Launch(ps, spec, ps.GetSize(), group)
+	if err != nil {
+		group.Remove()
+		return nil, ps.failed(err)
+	}
+	ps.limitsStarted(agent.PID())
+	ps.SetAgent(agent)
+	return agent, nil
+}
+
+// Adopt takes over an agent that an earlier run of this process launched
+// at the given time and a holder kept running, along with the group
+// enforcing its limits. The agent's output is replayed from offset, which
+// the session's output stream continues from.
+func (ps *PTYSession) Adopt(agent Agent, launched time.Time, offset int64, group *limits.Group) {
+	ps.SetLimits(group)
+
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	ps.scrollback.Reset(offset)
+	ps.Agent = agent
+	ps.lifecycle(func() error { return ps.Session.Adopted(agent.PID(), launched) })
+	ps.applySize()
+}
+
+// Snapshot returns a copy of the session's record, safe to use while the
+// session changes
+func (ps *PTYSession) Snapshot() *session.Session {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.Session.Clone()
+}
+
+// Status returns the session's lifecycle status
+func (ps *PTYSession) Status() string {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.Session.Status
+}
+
+// failed marks the session failed and returns err
+func (ps *PTYSession) failed(err error) error {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	ps.lifecycle(func() error { return ps.Session.Failed(err.Error()) })
+	return err
+}
+
+// Wait waits for the agent started by Start to exit, tears down anything
+// it left running and records how it ended. It reports whether the agent
+// exited by itself rather than being killed. ErrDetached means the agent
+// was left running, see Detach.
+func (ps *PTYSession) Wait(agent Agent) (bool, error) {
+	code, signal, err := agent.Wait()
+	if errors.Is(err, ErrDetached) {
+		return false, err
+	}
+	finishErr := ps.finish()
+	if err == nil {
+		err = finishErr
+	}
+
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	ps.paused = false
+	killed := ps.Session.Status == session.StatusKilled
+	if killed {
+		ps.Session.RecordExit(code, signal)
+	} else {
+		ps.lifecycle(func() error { return ps.Session.Exited(code, signal) })
+	}
+	ps.publish(Event{Type: EventExited, ExitCode: ps.Session.ExitCode, ExitSignal: ps.Session.ExitSignal})
+	return !killed, err
+}
+
+// Kill terminates a running agent and everything it started, recording
+// who asked for it. Wait then finishes the run. The session stays until it
+// is dismissed.
+func (ps *PTYSession) Kill(by string) error {
+	ps.Mu.Lock()
+	if ps.Session.Finished() {
+		ps.Mu.Unlock()
+		return ErrNotRunning
+	}
+	ps.lifecycle(func() error { return ps.Session.Killed(by) })
+	agent := ps.Agent
+	ps.Mu.Unlock()
+
+	if agent == nil {
+		return nil
+	}
+	return agent.Kill(process.DefaultGrace)
+}
+
+// Detach lets go of an agent kept by a holder, leaving it running so that
+// a later run of this process can adopt it, and closes the session. An
+// agent running as a child of this process cannot be detached.
+func (ps *PTYSession) Detach() error {
+	ps.Mu.RLock()
+	agent, held := ps.Agent.(interface{ Detach() error })
+	finished := ps.Session.Finished()
+	ps.Mu.RUnlock()
+	if !held || finished {
+		return ErrNotDetachable
+	}
+
+	err := agent.Detach()
+	ps.Close()
+	return err
+}
+
+// Relaunch prepares a finished session to be started again
+func (ps *PTYSession) Relaunch() error {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	return ps.relaunch()
+}
+
+// relaunch is Relaunch with Mu held
+func (ps *PTYSession) relaunch() error {
+	if !ps.Session.Finished() {
+		return ErrRunning
+	}
+	if err := ps.lifecycle(ps.Session.Relaunching); err != nil {
+		return err
+	}
+	ps.done = make(chan struct{})
+	return nil
+}
+
+// ScheduleRestart applies the session's restart policy once Wait has
+// returned. If the policy calls for a restart, the session is prepared to
+// start again after the policy's delay and start is called to launch it.
+// The restart is abandoned if the session is relaunched or closed in
+// the meantime. It reports whether a restart was scheduled, and which
+// restart it is.
+func (ps *PTYSession) ScheduleRestart(start func()) (int, bool) {
+	ps.Mu.Lock()
+	delay, restart := ps.Session.PlanRestart()
+	launches := ps.Session.Launches
+	restarts := ps.Session.Restarts
+	ps.Mu.Unlock()
+	if !restart {
+		return 0, false
+	}
+
+	time.AfterFunc(delay, func() {
+		ps.Mu.Lock()
+		defer ps.Mu.Unlock()
+		if ps.closed || ps.Session.Status != session.StatusExited || ps.Session.Launches != launches {
+			return
+		}
+		if err := ps.relaunch(); err != nil {
+			return
+		}
+		go start()
+	})
+	return restarts, true
+}
+
+// Close disconnects every client and finishes any recording, for a session
+// that is going away. The agent must already have been stopped.
+func (ps *PTYSession) Close() {
+	ps.Mu.Lock()
+	ps.closed = true
+	for _, client := range ps.Clients {
+		client.Close()
+	}
+	ps.Clients = make(map[*websocket.Conn]*Client)
+	ps.Session.Clients = 0
+	recorder := ps.takeRecorder()
+	ps.Mu.Unlock()
+
+	if recorder != nil {
+		recorder.Close()
+	}
+}
@@ -0,0 +1,148 @@
+package terminal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+func newLaunchedSession(t *testing.T, args ...string) *PTYSession {
+	t.Helper()
+	ps, err := NewPTYSession("test", session.NewSession("test", "/tmp", "main"))
+	if err != nil {
+		t.Fatalf("NewPTYSession: %v", err)
+	}
+	ps.SetLaunch(LaunchSpec{Command: args[0], Args: args[1:], Dir: t.TempDir()})
+	return ps
+}
+
+func TestLifecycleExitAndRelaunch(t *testing.T) {
+	ps := newLaunchedSession(t, "sh", "-c", "exit 3")
+
+	agent, err := ps.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := ps.Start(); err != ErrRunning {
+		t.Errorf("second Start = %v, want ErrRunning", err)
+	}
+	if exited, err := ps.Wait(agent); !exited || err != nil {
+		t.Fatalf("Wait = %v, %v", exited, err)
+	}
+	if s := ps.Session; s.Status != session.StatusExited || s.ExitCode == nil || *s.ExitCode != 3 || s.Ended == nil {
+		t.Fatalf("session = %+v", s)
+	}
+
+	if err := ps.Relaunch(); err != nil {
+		t.Fatalf("Relaunch: %v", err)
+	}
+	if s := ps.Session; s.Status != session.StatusStarting || s.ExitCode != nil {
+		t.Errorf("relaunching session = %+v", s)
+	}
+	agent, err = ps.Start()
+	if err != nil {
+		t.Fatalf("Start after relaunch: %v", err)
+	}
+	ps.Wait(agent)
+	if ps.Session.Launches != 2 {
+		t.Errorf("launches = %d, want 2", ps.Session.Launches)
+	}
+}
+
+func TestLifecycleKill(t *testing.T) {
+	ps := newLaunchedSession(t, "sleep", "30")
+	agent, err := ps.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := ps.Relaunch(); err != ErrRunning {
+		t.Errorf("Relaunch while running = %v, want ErrRunning", err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		exited, _ := ps.Wait(agent)
+		done <- exited
+	}()
+	if err := ps.Kill("tester"); err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+	if exited := <-done; exited {
+		t.Error("a killed agent should not count as exiting by itself")
+	}
+
+	s := ps.Session
+	if s.Status != session.StatusKilled || s.KilledBy != "tester" || s.ExitSignal != "SIGTERM" {
+		t.Errorf("session = %+v", s)
+	}
+	if err := ps.Kill("tester"); err != ErrNotRunning {
+		t.Errorf("second Kill = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestLifecycleFailedStart(t *testing.T) {
+	ps := newLaunchedSession(t, "/nonexistent/agent")
+	if _, err := ps.Start(); err == nil {
+		t.Fatal("Start should fail")
+	}
+	if s := ps.Session; s.Status != session.StatusFailed || s.Reason == "" {
+		t.Errorf("session = %+v", s)
+	}
+}
+
+func TestLifecycleRestart(t *testing.T) {
+	ps := newLaunchedSession(t, "sh", "-c", "exit 1")
+	ps.Session.Restart = &session.RestartPolicy{Policy: session.RestartOnFailure, MaxRetries: 2, Backoff: 0.01}
+
+	restarted := make(chan struct{}, 1)
+	var run func()
+	run = func() {
+		agent, err := ps.Start()
+		if err != nil {
+			t.Errorf("Start: %v", err)
+			return
+		}
+		if exited, _ := ps.Wait(agent); exited {
+			if _, scheduled := ps.ScheduleRestart(run); scheduled {
+				return
+			}
+		}
+		restarted <- struct{}{}
+	}
+	run()
+
+	select {
+	case <-restarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("restarts did not finish")
+	}
+	if s := ps.Session; s.Launches != 3 || s.Restarts != 2 || s.Status != session.StatusExited {
+		t.Errorf("session = %+v", s)
+	}
+}
+
+func TestLifecycleRestartAbandoned(t *testing.T) {
+	ps := newLaunchedSession(t, "sh", "-c", "exit 1")
+	ps.Session.Restart = &session.RestartPolicy{Policy: session.RestartAlways, Backoff: 0.05}
+	agent, err := ps.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	ps.Wait(agent)
+
+	started := make(chan struct{}, 1)
+	if restart, scheduled := ps.ScheduleRestart(func() { started <- struct{}{} }); !scheduled || restart != 1 {
+		t.Fatalf("ScheduleRestart = %d, %v, want restart 1", restart, scheduled)
+	}
+	ps.Close()
+
+	select {
+	case <-started:
+		t.Error("a closed session should not restart")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if ps.Session.Status != session.StatusExited {
+		t.Errorf("status = %s, want exited", ps.Session.Status)
+	}
+}
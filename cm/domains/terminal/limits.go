@@ -0,0 +1,62 @@
+package terminal
+
+import (
+	"github.com/user/claude-manager/domains/limits"
+)
+
+// SetLimits records the group enforcing the session's resource limits,
+// noting on the Session any limits that could not be applied as asked
+func (ps *PTYSession) SetLimits(group *limits.Group) {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	ps.limits = group
+	if group == nil {
+		ps.Session.Limits = nil
+		ps.Session.LimitMode = ""
+		return
+	}
+	applied := group.Limits()
+	ps.Session.Limits = &applied
+	ps.Session.LimitMode = group.Mode
+	ps.noteLimitWarnings()
+}
+
+// limitsStarted applies the limits that are set on the agent's process
+// once it is running, noting any that could not be
+func (ps *PTYSession) limitsStarted(pid int) {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	ps.limits.Started(pid)
+	ps.noteLimitWarnings()
+}
+
+// noteLimitWarnings records on the Session the limits that could not be
+// applied as asked. Must be called with Mu held.
+func (ps *PTYSession) noteLimitWarnings() {
+	for _, warning := range ps.limits.TakeWarnings() {
+		ps.Session.AddEvent("limits", warning)
+	}
+}
+
+// LimitMode returns how the session's resource limits are enforced, if
+// they are
+func (ps *PTYSession) LimitMode() string {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.Session.LimitMode
+}
+
+// CheckLimits records on the Session any resource limits the session has
+// run into since the last check, and returns them
+func (ps *PTYSession) CheckLimits() []string {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	breaches := ps.limits.Breaches()
+	for _, breach := range breaches {
+		ps.Session.AddEvent("limit_breach", breach)
+		ps.publish(Event{Type: EventLimitBreach, Message: breach})
+	}
+	return breaches
+}
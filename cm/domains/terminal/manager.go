@@ -1,99 +1,99 @@
 package terminal
 
 import (
-	"io"
-	"log"
+	"errors"
+	"sort"
 	"sync"
+
+	"github.com/user/claude-manager/domains/session"
 )
 
-// Manager manages all active PTY sessions
+// ErrExists is returned when adding a session whose ID is already taken
+var ErrExists = errors.New("session already exists")
+
+// Manager is the registry of sessions. It owns every session and its
+// terminal, and publishes what happens to them on its event bus.
 type Manager struct {
 	sessions map[string]*PTYSession
 	mu       sync.RWMutex
+	bus      *Bus
 }
 
-// NewManager creates a new terminal manager
+// NewManager creates an empty registry
 func NewManager() *Manager {
 	return &Manager{
 		sessions: make(map[string]*PTYSession),
+		bus:      NewBus(DefaultHistory),
 	}
 }
 
-// Add adds a new PTY session to the manager
-func (m *Manager) Add(session *PTYSession) {
+// Add registers a session, which from then on publishes its events
+func (m *Manager) Add(ps *PTYSession) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.sessions[session.ID] = session
-	
-	// Start reading from PTY and broadcasting to clients
-	go m.readPTYOutput(session)
+	if _, exists := m.sessions[ps.ID]; exists {
+		return ErrExists
+	}
+	m.sessions[ps.ID] = ps
+
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	ps.bus = m.bus
+	ps.publish(Event{Type: EventCreated})
+	return nil
 }
 
-// Get retrieves a PTY session by ID
+// Get retrieves a session by ID
 func (m *Manager) Get(id string) (*PTYSession, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	session, exists := m.sessions[id]
-	return session, exists
+	ps, exists := m.sessions[id]
+	return ps, exists
 }
 
-// Remove removes a PTY session from the manager
-func (m *Manager) Remove(id string) {
+// Remove unregisters a session and disconnects its clients. It returns the
+// session, if there was one.
+func (m *Manager) Remove(id string) (*PTYSession, bool) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-	
-	if session, exists := m.sessions[id]; exists {
-		session.Cleanup()
-		delete(m.sessions, id)
+	ps, exists := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	if !exists {
+		return nil, false
 	}
+
+	ps.Close()
+	ps.Mu.Lock()
+	ps.bus.Publish(Event{Type: EventRemoved, SessionID: id, Status: ps.Session.Status})
+	ps.bus = nil
+	ps.Mu.Unlock()
+	return ps, true
 }
 
-// List returns all PTY sessions
+// List returns every session, ordered by ID
 func (m *Manager) List() []*PTYSession {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-	
 	sessions := make([]*PTYSession, 0, len(m.sessions))
-	for _, session := range m.sessions {
-		sessions = append(sessions, session)
+	for _, ps := range m.sessions {
+		sessions = append(sessions, ps)
 	}
+	m.mu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
 	return sessions
 }
 
-// CleanupAll cleans up all PTY sessions
-func (m *Manager) CleanupAll() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	
-	for id, session := range m.sessions {
-		session.Cleanup()
-		delete(m.sessions, id)
+// Sessions returns a copy of every session's record, ordered by ID
+func (m *Manager) Sessions() []*session.Session {
+	list := m.List()
+	sessions := make([]*session.Session, 0, len(list))
+	for _, ps := range list {
+		sessions = append(sessions, ps.Snapshot())
 	}
+	return sessions
 }
 
-// readPTYOutput continuously reads from PTY and broadcasts to clients
-func (m *Manager) readPTYOutput(session *PTYSession) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("PTY reader panic for session %s: %v", session.ID, r)
-		}
-	}()
-
-	buffer := make([]byte, 1024)
-	for {
-		n, err := session.PTY.Read(buffer)
-		if err != nil {
-			if err == io.EOF {
-				log.Printf("PTY session %s ended", session.ID)
-			} else {
-				log.Printf("PTY read error for session %s: %v", session.ID, err)
-			}
-			m.Remove(session.ID)
-			return
-		}
-
-		if n > 0 {
-			session.BroadcastToClients(buffer[:n])
-		}
-	}
-}
\ No newline at end of file
+// Events returns the bus the registry publishes session events on
+func (m *Manager) Events() *Bus {
+	return m.bus
+}
@@ -0,0 +1,82 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+func TestManagerPublishesLifecycle(t *testing.T) {
+	m := NewManager()
+	events, unsubscribe := m.Events().Subscribe(16)
+	defer unsubscribe()
+
+	ps := newLaunchedSession(t, "sh", "-c", "exit 3")
+	if err := m.Add(ps); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := m.Add(ps); err != ErrExists {
+		t.Errorf("second Add = %v, want ErrExists", err)
+	}
+	agent, err := ps.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	ps.Wait(agent)
+	if _, removed := m.Remove(ps.ID); !removed {
+		t.Fatal("Remove found no session")
+	}
+	if _, exists := m.Get(ps.ID); exists {
+		t.Error("removed session is still registered")
+	}
+
+	want := []struct {
+		kind     EventType
+		previous string
+		status   string
+	}{
+		{EventCreated, "", session.StatusStarting},
+		{EventStatus, session.StatusStarting, session.StatusRunning},
+		{EventStatus, session.StatusRunning, session.StatusExited},
+		{EventExited, "", session.StatusExited},
+		{EventRemoved, "", session.StatusExited},
+	}
+	var last uint64
+	for i, w := range want {
+		event := <-events
+		if event.Type != w.kind || event.Previous != w.previous || event.Status != w.status || event.SessionID != ps.ID {
+			t.Fatalf("event %d = %+v, want %+v", i, event, w)
+		}
+		if event.ID <= last {
+			t.Errorf("event %d has ID %d after %d", i, event.ID, last)
+		}
+		last = event.ID
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %+v", event)
+	default:
+	}
+}
+
+func TestManagerEventSnapshots(t *testing.T) {
+	m := NewManager()
+	events, unsubscribe := m.Events().Subscribe(16)
+	defer unsubscribe()
+
+	ps := newLaunchedSession(t, "sh", "-c", "exit 3")
+	m.Add(ps)
+	agent, _ := ps.Start()
+	ps.Wait(agent)
+
+	created := <-events
+	<-events // running
+	<-events // exited
+	exited := <-events
+	if created.Session.Status != session.StatusStarting || created.Session == ps.Session {
+		t.Errorf("created snapshot = %+v", created.Session)
+	}
+	if exited.Type != EventExited || exited.ExitCode == nil || *exited.ExitCode != 3 || exited.Session.ExitCode == nil {
+		t.Errorf("exited event = %+v", exited)
+	}
+}
@@ -0,0 +1,69 @@
+package terminal
+
+import (
+	"context"
+	"time"
+)
+
+// OutputNotify returns a channel that is closed when the session next
+// produces output
+func (ps *PTYSession) OutputNotify() <-chan struct{} {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	if ps.outputNotify == nil {
+		ps.outputNotify = make(chan struct{})
+	}
+	return ps.outputNotify
+}
+
+// notifyOutput wakes anyone waiting for output. Must be called with Mu held.
+func (ps *PTYSession) notifyOutput() {
+	if ps.outputNotify != nil {
+		close(ps.outputNotify)
+		ps.outputNotify = nil
+	}
+}
+
+// OutputOffset returns the offset the next byte of output will have
+func (ps *PTYSession) OutputOffset() int64 {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.scrollback.End()
+}
+
+// OutputSince returns the output retained in the scrollback from offset
+// onwards, and the offset it actually starts at, which is later than the
+// one asked for if the scrollback has since wrapped
+func (ps *PTYSession) OutputSince(offset int64) ([]byte, int64) {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	if offset > ps.scrollback.End() {
+		return nil, ps.scrollback.End()
+	}
+	return ps.scrollback.ReadFrom(offset)
+}
+
+// WaitForQuiet blocks until the session has produced no output for settle,
+// or ctx is done
+func (ps *PTYSession) WaitForQuiet(ctx context.Context, settle time.Duration) error {
+	timer := time.NewTimer(settle)
+	defer timer.Stop()
+
+	done := ps.Done()
+	for {
+		notify := ps.OutputNotify()
+		select {
+		case <-notify:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(settle)
+		case <-timer.C:
+			return nil
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
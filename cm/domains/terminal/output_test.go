@@ -0,0 +1,56 @@
+package terminal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+func TestWaitForQuiet(t *testing.T) {
+	ps, err := NewPTYSession("test", session.NewSession("test", "/tmp", "main"))
+	if err != nil {
+		t.Fatalf("NewPTYSession: %v", err)
+	}
+	ps.BroadcastToClients([]byte("$ "))
+	offset := ps.OutputOffset()
+
+	// Output trickling in keeps the wait going until it stops
+	go func() {
+		for _, chunk := range []string{"make\r\n", "building...\r\n", "done\r\n"} {
+			time.Sleep(20 * time.Millisecond)
+			ps.BroadcastToClients([]byte(chunk))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ps.WaitForQuiet(ctx, 100*time.Millisecond); err != nil {
+		t.Fatalf("WaitForQuiet: %v", err)
+	}
+
+	output, from := ps.OutputSince(offset)
+	if string(output) != "make\r\nbuilding...\r\ndone\r\n" || from != offset {
+		t.Errorf("OutputSince(%d) = %q@%d", offset, output, from)
+	}
+
+	// A session that never goes quiet times out
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(5 * time.Millisecond):
+				ps.BroadcastToClients([]byte("."))
+			}
+		}
+	}()
+	if err := ps.WaitForQuiet(ctx, 30*time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("WaitForQuiet on a busy session = %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,79 @@
+package terminal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// Terminal output is sent to clients as binary frames (see NewOutputFrame).
+// Text frames carry JSON control messages.
+
+// Control message types sent by terminal clients as JSON text frames
+const (
+	MessageInput  = "input"
+	MessageResize = "resize"
+	MessageDriver = "driver"
+)
+
+// MessageRepaint redraws a newly attached client's screen
+const MessageRepaint = "repaint"
+
+// RepaintMessage carries output that reproduces the current screen on a
+// freshly reset terminal. Live output continues from Offset.
+type RepaintMessage struct {
+	Type   string `json:"type"`
+	Offset int64  `json:"offset"`
+	Data   string `json:"data"`
+}
+
+// OutputHeaderSize is the length of the offset header on output frames
+const OutputHeaderSize = 8
+
+// NewOutputFrame encodes a chunk of PTY output as a binary WebSocket frame:
+// the big-endian offset of the chunk's first byte in the session's output
+// stream followed by the raw bytes. Clients resume from offset+len(data).
+func NewOutputFrame(offset int64, data []byte) []byte {
+	frame := make([]byte, OutputHeaderSize+len(data))
+	binary.BigEndian.PutUint64(frame, uint64(offset))
+	copy(frame[OutputHeaderSize:], data)
+	return frame
+}
+
+// ParseOutputFrame decodes a binary output frame
+func ParseOutputFrame(frame []byte) (int64, []byte, error) {
+	if len(frame) < OutputHeaderSize {
+		return 0, nil, errors.New("output frame too short")
+	}
+	return int64(binary.BigEndian.Uint64(frame)), frame[OutputHeaderSize:], nil
+}
+
+// ControlMessage is a JSON control message sent over the terminal WebSocket
+type ControlMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+
+	ClientID string `json:"clientId,omitempty"`
+}
+
+// ParseControlMessage decodes a text frame from a client. It returns false
+// when the frame is not a known control message and should be treated as
+// raw terminal input instead.
+func ParseControlMessage(frame []byte) (ControlMessage, bool) {
+	var msg ControlMessage
+	if len(frame) == 0 || frame[0] != '{' {
+		return msg, false
+	}
+	if err := json.Unmarshal(frame, &msg); err != nil {
+		return msg, false
+	}
+
+	switch msg.Type {
+	case MessageInput, MessageResize, MessageDriver:
+		return msg, true
+	default:
+		return msg, false
+	}
+}
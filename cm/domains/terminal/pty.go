@@ -1,89 +1,313 @@
 package terminal
 
 import (
-	"os"
-	"os/exec"
+	"errors"
+	"fmt"
+	"regexp"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
-	
+
+	"github.com/user/claude-manager/domains/attention"
+	"github.com/user/claude-manager/domains/limits"
+	"github.com/user/claude-manager/domains/process"
+	"github.com/user/claude-manager/domains/screen"
 	"github.com/user/claude-manager/domains/session"
 )
 
+// Default window size used until a client reports its own
+const (
+	DefaultRows = 24
+	DefaultCols = 80
+)
+
+// Size is a terminal window size in character cells
+type Size struct {
+	Rows uint16 `json:"rows"`
+	Cols uint16 `json:"cols"`
+}
+
 // PTYSession manages a pseudoterminal session
 type PTYSession struct {
 	ID      string
-	PTY     *os.File
-	Cmd     *exec.Cmd
+	Agent   Agent // the running agent, once started
 	Session *session.Session
-	Clients map[*websocket.Conn]bool
+	Clients map[*websocket.Conn]*Client
 	Mu      sync.RWMutex
+
+	scrollback *Scrollback    // output history replayed to resuming clients
+	screen     *screen.Screen // what the terminal currently displays
+	recorder   Recorder       // optional recording of the session's traffic
+	attention  *attention.Detector
+	ready      *regexp.Regexp // shows the agent has started, see SetDetection
+
+	outputNotify chan struct{} // closed when output arrives, see OutputNotify
+	paused       bool          // stopped with SIGSTOP or SIGTSTP
+	usage        process.Sampler
+	limits       *limits.Group // resource limits, if any were asked for
+
+	launch LaunchSpec    // how the agent is started, see Start
+	done   chan struct{} // closed when the agent's run ends
+	closed bool          // the session is going away, see Close
+	bus    *Bus          // the registry's, once the session is registered
 }
 
 // NewPTYSession creates a new PTY session
 func NewPTYSession(id string, session *session.Session) (*PTYSession, error) {
+	session.SetSize(DefaultRows, DefaultCols)
+	session.Clients = 0
+	detector := attention.NewDetector(time.Now())
+	state, since := detector.State()
+	session.SetActivity(string(state), since, detector.LastOutput())
 	return &PTYSession{
-		ID:      id,
-		Session: session,
-		Clients: make(map[*websocket.Conn]bool),
+		ID:         id,
+		Session:    session,
+		Clients:    make(map[*websocket.Conn]*Client),
+		scrollback: NewScrollback(DefaultScrollbackSize),
+		screen:     screen.New(DefaultRows, DefaultCols),
+		attention:  detector,
+		done:       make(chan struct{}),
 	}, nil
 }
 
-// AddClient adds a websocket client to the PTY session
-func (ps *PTYSession) AddClient(conn *websocket.Conn) {
+// SetAgent attaches a started agent to the session, marks it running and
+// applies the size negotiated by any clients that connected before it was
+// ready
+func (ps *PTYSession) SetAgent(agent Agent) {
 	ps.Mu.Lock()
 	defer ps.Mu.Unlock()
-	ps.Clients[conn] = true
+	ps.Agent = agent
+	ps.lifecycle(func() error { return ps.Session.Started(agent.PID()) })
+	ps.applySize()
 }
 
-// RemoveClient removes a websocket client from the PTY session
+// AddClient adds a websocket client to the PTY session. A client resuming
+// from an offset still retained in the scrollback is sent the output it
+// missed, so it sees no gaps or duplicates. Any other client is sent a
+// repaint of the current screen before it joins the live stream.
+func (ps *PTYSession) AddClient(conn *websocket.Conn, opts AttachOptions) *Client {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	client := newClient(conn, opts.Name)
+	if opts.Resume && opts.Offset >= ps.scrollback.Start() && opts.Offset <= ps.scrollback.End() {
+		data, from := ps.scrollback.ReadFrom(opts.Offset)
+		if len(data) > 0 {
+			client.sendOutput(from, NewOutputFrame(from, data))
+		}
+	} else {
+		client.SendJSON(RepaintMessage{
+			Type:   MessageRepaint,
+			Offset: ps.scrollback.End(),
+			Data:   string(ps.screen.Repaint()),
+		})
+	}
+
+	ps.Clients[conn] = client
+	ps.Session.Clients = len(ps.Clients)
+	ps.assignRole(client, opts.Role)
+	ps.broadcastRoles()
+	ps.publish(Event{Type: EventClientAttached})
+	return client
+}
+
+// RemoveClient removes a websocket client from the PTY session and
+// renegotiates the window size without it
 func (ps *PTYSession) RemoveClient(conn *websocket.Conn) {
 	ps.Mu.Lock()
 	defer ps.Mu.Unlock()
+
+	client, exists := ps.Clients[conn]
+	if !exists {
+		return
+	}
 	delete(ps.Clients, conn)
+	ps.Session.Clients = len(ps.Clients)
+	client.Close()
+	if client.size != (Size{}) {
+		ps.applySize()
+	}
+	ps.broadcastRoles()
+	ps.publish(Event{Type: EventClientLeft})
 }
 
-// BroadcastToClients sends data to all connected clients
-func (ps *PTYSession) BroadcastToClients(data []byte) {
+// SetClientSize records the window size reported by a client and resizes
+// the PTY. Like tmux, the smallest attached client wins so that every
+// viewer sees the whole screen.
+func (ps *PTYSession) SetClientSize(conn *websocket.Conn, rows, cols uint16) error {
+	if rows == 0 || cols == 0 {
+		return errors.New("invalid terminal size")
+	}
+
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	client, attached := ps.Clients[conn]
+	if !attached {
+		return errors.New("client not attached")
+	}
+	client.size = Size{Rows: rows, Cols: cols}
+	return ps.applySize()
+}
+
+// negotiatedSize returns the smallest size reported by attached clients.
+// Must be called with Mu held.
+func (ps *PTYSession) negotiatedSize() (Size, bool) {
+	var size Size
+	for _, client := range ps.Clients {
+		s := client.size
+		if s == (Size{}) {
+			continue
+		}
+		if size.Rows == 0 || s.Rows < size.Rows {
+			size.Rows = s.Rows
+		}
+		if size.Cols == 0 || s.Cols < size.Cols {
+			size.Cols = s.Cols
+		}
+	}
+	return size, size != (Size{})
+}
+
+// applySize resizes the PTY to the negotiated size. When no client has
+// reported a size the current one is kept. Must be called with Mu held.
+func (ps *PTYSession) applySize() error {
+	size, ok := ps.negotiatedSize()
+	if !ok {
+		return nil
+	}
+	if ps.Agent != nil {
+		if err := ps.Agent.Resize(size.Rows, size.Cols); err != nil {
+			return err
+		}
+	}
+	if ps.recorder != nil && (size.Rows != ps.Session.Rows || size.Cols != ps.Session.Cols) {
+		ps.recorder.RecordResize(size.Rows, size.Cols)
+	}
+	ps.screen.Resize(int(size.Rows), int(size.Cols))
+	ps.Session.SetSize(size.Rows, size.Cols)
+	return nil
+}
+
+// GetSize returns the current PTY window size
+func (ps *PTYSession) GetSize() Size {
 	ps.Mu.RLock()
 	defer ps.Mu.RUnlock()
+	return Size{Rows: ps.Session.Rows, Cols: ps.Session.Cols}
+}
 
-	for client := range ps.Clients {
-		if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
-			// Remove client on error
-			delete(ps.Clients, client)
-			client.Close()
-		}
+// SetBranch records the branch now checked out in the session's
+// directory, and reports whether it changed
+func (ps *PTYSession) SetBranch(branch string) bool {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+	if branch == ps.Session.Branch {
+		return false
 	}
+	ps.Session.AddEvent("worktree", fmt.Sprintf("branch changed from %s to %s", ps.Session.Branch, branch))
+	ps.Session.Branch = branch
+	ps.publish(Event{Type: EventBranch})
+	return true
+}
+
+// BroadcastToClients records data in the scrollback and on the screen and
+// queues it for all connected clients. Queuing never blocks, so a slow client cannot stall
+// the PTY reader.
+func (ps *PTYSession) BroadcastToClients(data []byte) {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	offset := ps.scrollback.Write(data)
+	ps.screen.Write(data)
+	ps.attention.Output(time.Now())
+	ps.notifyOutput()
+	frame := NewOutputFrame(offset, data)
+	if ps.recorder != nil {
+		ps.recorder.RecordOutput(data)
+	}
+
+	for _, client := range ps.Clients {
+		client.sendOutput(offset, frame)
+	}
+}
+
+// ScreenText returns the visible screen as plain text
+func (ps *PTYSession) ScreenText() string {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.screen.Text()
+}
+
+// ScreenSnapshot returns the visible screen with cell attributes
+func (ps *PTYSession) ScreenSnapshot() screen.Snapshot {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.screen.Snapshot()
 }
 
 // WriteInput writes input to the PTY
 func (ps *PTYSession) WriteInput(data []byte) error {
-	_, err := ps.PTY.Write(data)
+	ps.Mu.Lock()
+	agent := ps.Agent
+	if agent == nil {
+		ps.Mu.Unlock()
+		return errors.New("PTY not started")
+	}
+	if ps.recorder != nil {
+		ps.recorder.RecordInput(data)
+	}
+	ps.attention.Input(time.Now())
+	ps.Mu.Unlock()
+
+	_, err := agent.Write(data)
 	return err
 }
 
-// Cleanup closes the PTY and terminates the command
-func (ps *PTYSession) Cleanup() {
-	ps.Mu.Lock()
-	defer ps.Mu.Unlock()
+// Cleanup ends the agent's run: it terminates every process in the
+// session, closes the terminal and removes the session's cgroup. Clients
+// stay attached. The error names any processes that could not be killed,
+// or the cgroup that could not be removed.
+func (ps *PTYSession) Cleanup() error {
+	ps.Mu.RLock()
+	agent := ps.Agent
+	ps.Mu.RUnlock()
 
-	// Close all websocket connections
-	for client := range ps.Clients {
-		client.Close()
+	var err error
+	if agent != nil {
+		err = agent.Kill(process.DefaultGrace)
 	}
-	ps.Clients = make(map[*websocket.Conn]bool)
+	if finishErr := ps.finish(); err == nil {
+		err = finishErr
+	}
+	return err
+}
 
-	// Close PTY
-	if ps.PTY != nil {
-		ps.PTY.Close()
+// finish ends the agent's run once its processes are gone: it closes the
+// terminal and removes the session's cgroup
+func (ps *PTYSession) finish() error {
+	ps.Mu.Lock()
+	select {
+	case <-ps.done:
+	default:
+		close(ps.done)
 	}
+	agent := ps.Agent
+	group := ps.limits
+	ps.Mu.Unlock()
 
-	// Terminate command
-	if ps.Cmd != nil && ps.Cmd.Process != nil {
-		ps.Cmd.Process.Kill()
-		ps.Cmd.Wait()
+	if agent != nil {
+		agent.Close()
 	}
+	// The cgroup can only go once its processes have
+	return group.Remove()
+}
+
+// Done returns a channel that is closed once the agent's current run has
+// ended
+func (ps *PTYSession) Done() <-chan struct{} {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.done
 }
 
 // GetClientCount returns the number of connected clients
@@ -91,4 +315,4 @@ func (ps *PTYSession) GetClientCount() int {
 	ps.Mu.RLock()
 	defer ps.Mu.RUnlock()
 	return len(ps.Clients)
-}
\ No newline at end of file
+}
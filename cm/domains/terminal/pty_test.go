@@ -0,0 +1,122 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+// sizeAgent stands in for a running agent, recording the size its
+// terminal is set to
+type sizeAgent struct {
+	Agent
+	size Size
+}
+
+func (a *sizeAgent) Resize(rows, cols uint16) error {
+	a.size = Size{Rows: rows, Cols: cols}
+	return nil
+}
+
+func TestSizeNegotiation(t *testing.T) {
+	type step struct {
+		client     string
+		rows, cols uint16
+		detach     bool
+	}
+	tests := []struct {
+		name    string
+		steps   []step
+		want    Size
+		resized bool
+	}{
+		{
+			name:    "one client",
+			steps:   []step{{client: "a", rows: 40, cols: 120}},
+			want:    Size{Rows: 40, Cols: 120},
+			resized: true,
+		},
+		{
+			name:    "smallest client wins",
+			steps:   []step{{client: "a", rows: 40, cols: 120}, {client: "b", rows: 30, cols: 160}},
+			want:    Size{Rows: 30, Cols: 120},
+			resized: true,
+		},
+		{
+			name:  "zero size ignored",
+			steps: []step{{client: "a", rows: 0, cols: 120}, {client: "a", rows: 40, cols: 0}},
+			want:  Size{Rows: DefaultRows, Cols: DefaultCols},
+		},
+		{
+			name:    "invalid size keeps the last valid one",
+			steps:   []step{{client: "a", rows: 40, cols: 120}, {client: "a", rows: 0, cols: 0}},
+			want:    Size{Rows: 40, Cols: 120},
+			resized: true,
+		},
+		{
+			name:    "client without a size does not count",
+			steps:   []step{{client: "a", rows: 40, cols: 120}, {client: "b"}},
+			want:    Size{Rows: 40, Cols: 120},
+			resized: true,
+		},
+		{
+			name: "grows when the smaller client leaves",
+			steps: []step{
+				{client: "a", rows: 40, cols: 120},
+				{client: "b", rows: 20, cols: 80},
+				{client: "b", detach: true},
+			},
+			want:    Size{Rows: 40, Cols: 120},
+			resized: true,
+		},
+		{
+			name: "last client leaving keeps the size",
+			steps: []step{
+				{client: "a", rows: 40, cols: 120},
+				{client: "a", detach: true},
+			},
+			want:    Size{Rows: 40, Cols: 120},
+			resized: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, err := NewPTYSession("test", session.NewSession("test", "/tmp", "main"))
+			if err != nil {
+				t.Fatalf("NewPTYSession: %v", err)
+			}
+			agent := &sizeAgent{}
+			ps.Agent = agent
+
+			conns := make(map[string]*websocket.Conn)
+			for _, s := range tt.steps {
+				conn, attached := conns[s.client]
+				if !attached {
+					conn = &websocket.Conn{}
+					conns[s.client] = conn
+					ps.Clients[conn] = &Client{ID: s.client, send: make(chan outbound, 16), done: make(chan struct{})}
+				}
+				if s.detach {
+					ps.RemoveClient(conn)
+					continue
+				}
+				if s.rows != 0 || s.cols != 0 {
+					err := ps.SetClientSize(conn, s.rows, s.cols)
+					if invalid := s.rows == 0 || s.cols == 0; invalid != (err != nil) {
+						t.Errorf("SetClientSize(%d, %d) = %v", s.rows, s.cols, err)
+					}
+				}
+			}
+
+			if got := ps.GetSize(); got != tt.want {
+				t.Errorf("size = %+v, want %+v", got, tt.want)
+			}
+			if resized := agent.size != (Size{}); resized != tt.resized || (resized && agent.size != tt.want) {
+				t.Errorf("agent resized to %+v, want %+v", agent.size, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package terminal
+
+// Recorder receives a copy of a session's terminal traffic, in the order
+// it passes through the session. Recording must not block, as it is done
+// with the session locked.
+type Recorder interface {
+	RecordOutput(data []byte)
+	RecordInput(data []byte)
+	RecordResize(rows, cols uint16)
+	Close() error
+}
+
+// StartRecording attaches a recorder writing to path to the session,
+// finishing any recording already in progress
+func (ps *PTYSession) StartRecording(recorder Recorder, path string) {
+	ps.Mu.Lock()
+	previous := ps.takeRecorder()
+	ps.recorder = recorder
+	ps.Session.Recording = path
+	ps.Mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+}
+
+// StopRecording detaches and closes the session's recorder
+func (ps *PTYSession) StopRecording() error {
+	ps.Mu.Lock()
+	recorder := ps.takeRecorder()
+	ps.Mu.Unlock()
+
+	if recorder == nil {
+		return nil
+	}
+	return recorder.Close()
+}
+
+// takeRecorder detaches the session's recorder, which the caller closes
+// once it has released Mu. Must be called with Mu held.
+func (ps *PTYSession) takeRecorder() Recorder {
+	recorder := ps.recorder
+	ps.recorder = nil
+	ps.Session.Recording = ""
+	return recorder
+}
+
+// IsRecording reports whether a recorder is attached
+func (ps *PTYSession) IsRecording() bool {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.recorder != nil
+}
+
+// RecordingPath returns the file the session is being recorded to, if it
+// is being recorded
+func (ps *PTYSession) RecordingPath() string {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.Session.Recording
+}
@@ -0,0 +1,204 @@
+package terminal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client roles. Only the driver's input reaches the PTY; viewers watch.
+const (
+	RoleDriver = "driver"
+	RoleViewer = "viewer"
+)
+
+// MessageRoles tells clients who is attached and who is driving
+const MessageRoles = "roles"
+
+// Errors returned when changing the driver
+var (
+	ErrClientNotFound = errors.New("client not found")
+	ErrNotDriver      = errors.New("only the driver can hand over control")
+)
+
+// ClientInfo describes an attached client
+type ClientInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Role      string    `json:"role"`
+	Rows      uint16    `json:"rows,omitempty"`
+	Cols      uint16    `json:"cols,omitempty"`
+	Connected time.Time `json:"connected"`
+}
+
+// RolesMessage is sent to every client whenever roles change. You is the
+// receiving client's own ID.
+type RolesMessage struct {
+	Type    string       `json:"type"`
+	You     string       `json:"you"`
+	Driver  string       `json:"driver"`
+	Clients []ClientInfo `json:"clients"`
+}
+
+// AttachOptions configure a client attaching to a session
+type AttachOptions struct {
+	Offset int64  // scrollback offset to resume from
+	Resume bool   // Offset was given; otherwise the client gets a repaint
+	Role   string // requested role; empty takes the driver seat if it is free
+	Name   string // display name shown to other clients
+}
+
+// info returns the client's description. Must be called with PTYSession.Mu held.
+func (c *Client) info() ClientInfo {
+	return ClientInfo{
+		ID:        c.ID,
+		Name:      c.Name,
+		Role:      c.role,
+		Rows:      c.size.Rows,
+		Cols:      c.size.Cols,
+		Connected: c.connected,
+	}
+}
+
+// ListClients returns the attached clients
+func (ps *PTYSession) ListClients() []ClientInfo {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+
+	clients := make([]ClientInfo, 0, len(ps.Clients))
+	for _, client := range ps.Clients {
+		clients = append(clients, client.info())
+	}
+	return clients
+}
+
+// Driver returns the ID of the client currently driving, or "" if nobody is
+func (ps *PTYSession) Driver() string {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	if driver := ps.driver(); driver != nil {
+		return driver.ID
+	}
+	return ""
+}
+
+// IsDriver reports whether input from conn should reach the PTY
+func (ps *PTYSession) IsDriver(conn *websocket.Conn) bool {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	client, attached := ps.Clients[conn]
+	return attached && client.role == RoleDriver
+}
+
+// SetDriver hands the driver role to the client with the given ID. The
+// previous driver becomes a viewer.
+func (ps *PTYSession) SetDriver(clientID string) error {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	target := ps.clientByID(clientID)
+	if target == nil {
+		return ErrClientNotFound
+	}
+	ps.promote(target)
+	ps.broadcastRoles()
+	return nil
+}
+
+// RequestDriver handles a driver change asked for by an attached client.
+// The current driver may hand control to anyone; any client may take
+// control while the seat is empty. An empty clientID means the requester.
+func (ps *PTYSession) RequestDriver(from *Client, clientID string) error {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	if clientID == "" {
+		clientID = from.ID
+	}
+	target := ps.clientByID(clientID)
+	if target == nil {
+		return ErrClientNotFound
+	}
+	if driver := ps.driver(); driver != nil && driver != from {
+		return ErrNotDriver
+	}
+	ps.promote(target)
+	ps.broadcastRoles()
+	return nil
+}
+
+// assignRole gives a newly attached client its role. Must be called with Mu held.
+func (ps *PTYSession) assignRole(client *Client, requested string) {
+	switch {
+	case requested == RoleDriver:
+		ps.promote(client)
+	case requested == "" && ps.driver() == nil:
+		client.role = RoleDriver
+	default:
+		client.role = RoleViewer
+	}
+}
+
+// promote makes client the only driver. Must be called with Mu held.
+func (ps *PTYSession) promote(client *Client) {
+	for _, c := range ps.Clients {
+		if c.role == RoleDriver {
+			c.role = RoleViewer
+		}
+	}
+	client.role = RoleDriver
+}
+
+// driver returns the driving client. Must be called with Mu held.
+func (ps *PTYSession) driver() *Client {
+	for _, client := range ps.Clients {
+		if client.role == RoleDriver {
+			return client
+		}
+	}
+	return nil
+}
+
+// clientByID looks up an attached client. Must be called with Mu held.
+func (ps *PTYSession) clientByID(id string) *Client {
+	for _, client := range ps.Clients {
+		if client.ID == id {
+			return client
+		}
+	}
+	return nil
+}
+
+// broadcastRoles tells every client the current roles. Must be called with Mu held.
+func (ps *PTYSession) broadcastRoles() {
+	clients := make([]ClientInfo, 0, len(ps.Clients))
+	driverID := ""
+	for _, client := range ps.Clients {
+		clients = append(clients, client.info())
+		if client.role == RoleDriver {
+			driverID = client.ID
+		}
+	}
+
+	for _, client := range ps.Clients {
+		client.SendJSON(RolesMessage{
+			Type:    MessageRoles,
+			You:     client.ID,
+			Driver:  driverID,
+			Clients: clients,
+		})
+	}
+}
+
+// generateClientID generates a short unique client ID
+func generateClientID() string {
+	bytes := make([]byte, 4)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("client-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,55 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDriverRoles(t *testing.T) {
+	ps := &PTYSession{
+		Clients:    make(map[*websocket.Conn]*Client),
+		scrollback: NewScrollback(1024),
+	}
+	attach := func(conn *websocket.Conn, role string) *Client {
+		client := &Client{ID: role + "-client", send: make(chan outbound, 16), done: make(chan struct{})}
+		ps.Clients[conn] = client
+		ps.assignRole(client, role)
+		return client
+	}
+
+	first, second, watcher := &websocket.Conn{}, &websocket.Conn{}, &websocket.Conn{}
+	driver := attach(first, "")
+	viewer := attach(second, "")
+	observer := attach(watcher, RoleViewer)
+
+	if !ps.IsDriver(first) || ps.IsDriver(second) || ps.IsDriver(watcher) {
+		t.Fatalf("first client should be the only driver, got driver %q", ps.Driver())
+	}
+
+	// A viewer cannot take control while someone is driving
+	if err := ps.RequestDriver(viewer, ""); err != ErrNotDriver {
+		t.Errorf("RequestDriver by viewer = %v, want ErrNotDriver", err)
+	}
+
+	// The driver can hand control to a viewer
+	if err := ps.RequestDriver(driver, observer.ID); err != nil {
+		t.Fatalf("RequestDriver by driver: %v", err)
+	}
+	if !ps.IsDriver(watcher) || ps.IsDriver(first) {
+		t.Errorf("driver = %q, want %q", ps.Driver(), observer.ID)
+	}
+
+	// Once the seat is empty anyone may take it
+	delete(ps.Clients, watcher)
+	if err := ps.RequestDriver(viewer, ""); err != nil {
+		t.Errorf("RequestDriver with empty seat: %v", err)
+	}
+	if ps.Driver() != viewer.ID {
+		t.Errorf("driver = %q, want %q", ps.Driver(), viewer.ID)
+	}
+
+	if err := ps.SetDriver("missing"); err != ErrClientNotFound {
+		t.Errorf("SetDriver(missing) = %v, want ErrClientNotFound", err)
+	}
+}
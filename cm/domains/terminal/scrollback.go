@@ -0,0 +1,96 @@
+package terminal
+
+import (
+	"unicode/utf8"
+)
+
+// DefaultScrollbackSize is the number of output bytes kept per session for
+// replay to newly attached clients
+var DefaultScrollbackSize = 256 * 1024
+
+// Scrollback is a bounded ring buffer of PTY output. Every byte written is
+// addressed by its absolute offset in the session's output stream, so a
+// client can resume from the last offset it saw. It is not safe for
+// concurrent use; PTYSession guards it with Mu.
+type Scrollback struct {
+	buf   []byte
+	start int64 // offset of the oldest retained byte
+	end   int64 // offset one past the newest byte
+}
+
+// NewScrollback creates a scrollback buffer holding up to limit bytes
+func NewScrollback(limit int) *Scrollback {
+	if limit <= 0 {
+		limit = DefaultScrollbackSize
+	}
+	return &Scrollback{buf: make([]byte, limit)}
+}
+
+// Write appends output and returns the offset of its first byte
+func (sb *Scrollback) Write(p []byte) int64 {
+	offset := sb.end
+	limit := int64(len(sb.buf))
+
+	// Only the tail of an oversized chunk can be retained
+	data := p
+	if int64(len(data)) > limit {
+		data = data[int64(len(data))-limit:]
+	}
+
+	pos := (offset + int64(len(p)) - int64(len(data))) % limit
+	n := copy(sb.buf[pos:], data)
+	copy(sb.buf, data[n:])
+
+	sb.end += int64(len(p))
+	if sb.end-sb.start > limit {
+		sb.start = sb.end - limit
+	}
+	return offset
+}
+
+// ReadFrom returns the retained output starting at offset along with the
+// offset it actually starts at. Offsets that have already been evicted are
+// clamped to the oldest retained byte; offsets beyond the end of the stream
+// (for example from a previous server run) replay everything retained.
+func (sb *Scrollback) ReadFrom(offset int64) ([]byte, int64) {
+	if offset < sb.start || offset > sb.end {
+		offset = sb.start
+
+		// Eviction may have cut a multi-byte character in half, so skip
+		// continuation bytes until the first character boundary
+		for i := 0; i < utf8.UTFMax-1 && offset < sb.end; i++ {
+			if utf8.RuneStart(sb.buf[offset%int64(len(sb.buf))]) {
+				break
+			}
+			offset++
+		}
+	}
+
+	length := sb.end - offset
+	data := make([]byte, length)
+	if length == 0 {
+		return data, offset
+	}
+
+	limit := int64(len(sb.buf))
+	pos := offset % limit
+	n := copy(data, sb.buf[pos:])
+	copy(data[n:], sb.buf)
+	return data, offset
+}
+
+// Reset discards the retained output and continues the stream at offset
+func (sb *Scrollback) Reset(offset int64) {
+	sb.start = offset
+	sb.end = offset
+}
+
+// Start returns the offset of the oldest retained byte
+func (sb *Scrollback) Start() int64 {
+	return sb.start
+}
+
+// End returns the offset one past the newest byte written
+func (sb *Scrollback) End() int64 {
+	return sb.end
+}
@@ -0,0 +1,51 @@
+package terminal
+
+import (
+	"testing"
+)
+
+func TestScrollbackReplay(t *testing.T) {
+	sb := NewScrollback(8)
+
+	if off := sb.Write([]byte("hello")); off != 0 {
+		t.Errorf("first write offset = %d, want 0", off)
+	}
+	if off := sb.Write([]byte("world")); off != 5 {
+		t.Errorf("second write offset = %d, want 5", off)
+	}
+
+	tests := []struct {
+		name     string
+		offset   int64
+		want     string
+		wantFrom int64
+	}{
+		{name: "evicted offset clamps to oldest", offset: 0, want: "lloworld", wantFrom: 2},
+		{name: "resume mid stream", offset: 7, want: "rld", wantFrom: 7},
+		{name: "caught up", offset: 10, want: "", wantFrom: 10},
+		{name: "offset from the future", offset: 99, want: "lloworld", wantFrom: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, from := sb.ReadFrom(tt.offset)
+			if string(data) != tt.want || from != tt.wantFrom {
+				t.Errorf("ReadFrom(%d) = %q@%d, want %q@%d", tt.offset, data, from, tt.want, tt.wantFrom)
+			}
+		})
+	}
+}
+
+func TestScrollbackOversizedWrite(t *testing.T) {
+	sb := NewScrollback(4)
+	sb.Write([]byte("ab"))
+	sb.Write([]byte("0123456789"))
+
+	data, from := sb.ReadFrom(0)
+	if string(data) != "6789" || from != 8 {
+		t.Errorf("ReadFrom(0) = %q@%d, want %q@%d", data, from, "6789", 8)
+	}
+	if sb.End() != 12 {
+		t.Errorf("End() = %d, want 12", sb.End())
+	}
+}
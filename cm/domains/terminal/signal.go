@@ -0,0 +1,126 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/user/claude-manager/domains/process"
+	"github.com/user/claude-manager/domains/session"
+)
+
+// Signals that may be delivered to a session
+var Signals = map[string]syscall.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGTSTP": syscall.SIGTSTP,
+	"SIGSTOP": syscall.SIGSTOP,
+	"SIGCONT": syscall.SIGCONT,
+}
+
+// ErrNotRunning is returned when a session has no process to signal
+var ErrNotRunning = errors.New("session process is not running")
+
+// ParseSignal looks up a signal by name, with or without the SIG prefix
+func ParseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := Signals[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported signal %q", name)
+	}
+	return sig, nil
+}
+
+// Signal delivers sig to the session's process group, and to the
+// terminal's foreground process group if a job running in the session has
+// one of its own. Stopping signals mark the session paused and SIGCONT
+// marks it active again.
+func (ps *PTYSession) Signal(sig syscall.Signal) error {
+	ps.Mu.Lock()
+	defer ps.Mu.Unlock()
+
+	if ps.Agent == nil || ps.Session.Finished() {
+		return ErrNotRunning
+	}
+
+	groups := []int{ps.Agent.PID()}
+	if fg, err := foregroundGroup(groups[0]); err == nil && fg > 0 && fg != groups[0] {
+		groups = append(groups, fg)
+	}
+
+	delivered := false
+	var lastErr error
+	for _, pgid := range groups {
+		if err := syscall.Kill(-pgid, sig); err != nil {
+			lastErr = err
+			continue
+		}
+		delivered = true
+	}
+	if !delivered {
+		if errors.Is(lastErr, syscall.ESRCH) {
+			return ErrNotRunning
+		}
+		return lastErr
+	}
+
+	switch sig {
+	case syscall.SIGSTOP, syscall.SIGTSTP:
+		if !ps.paused {
+			ps.paused = true
+			ps.lifecycle(func() error { return ps.Session.Transition(session.StatusPaused, "paused") })
+		}
+	case syscall.SIGCONT:
+		ps.resumed()
+	default:
+		// A stopped process only acts on the signal once it is continued
+		if ps.paused {
+			for _, pgid := range groups {
+				syscall.Kill(-pgid, syscall.SIGCONT)
+			}
+			ps.resumed()
+		}
+	}
+	return nil
+}
+
+// resumed marks a paused session active again. Must be called with Mu held.
+func (ps *PTYSession) resumed() {
+	if ps.paused {
+		ps.paused = false
+		ps.lifecycle(func() error { return ps.Session.Transition(session.StatusRunning, "resumed") })
+	}
+}
+
+// Pause stops every process in the session without losing its state
+func (ps *PTYSession) Pause() error {
+	return ps.Signal(syscall.SIGSTOP)
+}
+
+// Resume continues a paused session
+func (ps *PTYSession) Resume() error {
+	return ps.Signal(syscall.SIGCONT)
+}
+
+// IsPaused reports whether the session has been stopped
+func (ps *PTYSession) IsPaused() bool {
+	ps.Mu.RLock()
+	defer ps.Mu.RUnlock()
+	return ps.paused
+}
+
+// foregroundGroup returns the foreground process group of the terminal
+// the leader runs on. It is read from /proc, which works just as well for
+// an agent whose terminal is kept by a holder.
+func foregroundGroup(leader int) (int, error) {
+	stat, err := process.ReadStat(leader)
+	if err != nil {
+		return 0, err
+	}
+	return stat.TPGID, nil
+}
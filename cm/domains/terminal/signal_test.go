@@ -0,0 +1,93 @@
+package terminal
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+// processState returns the state letter from /proc/<pid>/stat
+func processState(t *testing.T, pid int) string {
+	t.Helper()
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		t.Fatalf("read stat: %v", err)
+	}
+	fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+	return fields[0]
+}
+
+func waitForState(t *testing.T, pid int, want string) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if processState(t, pid) == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("process %d state = %s, want %s", pid, processState(t, pid), want)
+}
+
+func TestSignalPauseResume(t *testing.T) {
+	ps, err := NewPTYSession("test", session.NewSession("test", "/tmp", "main"))
+	if err != nil {
+		t.Fatalf("NewPTYSession: %v", err)
+	}
+	if err := ps.Pause(); err != ErrNotRunning {
+		t.Errorf("Pause before start = %v, want ErrNotRunning", err)
+	}
+
+	cmd := exec.Command("sleep", "30")
+	ptyFile, err := pty.Start(cmd)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer ptyFile.Close()
+	ps.SetAgent(NewLocalAgent(ptyFile, cmd))
+	pid := cmd.Process.Pid
+
+	if err := ps.Pause(); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	waitForState(t, pid, "T")
+	if !ps.IsPaused() || ps.Session.Status != session.StatusPaused {
+		t.Errorf("paused = %v, status %q", ps.IsPaused(), ps.Session.Status)
+	}
+
+	if err := ps.Resume(); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	waitForState(t, pid, "S")
+	if ps.IsPaused() || ps.Session.Status != session.StatusRunning {
+		t.Errorf("paused = %v, status %q", ps.IsPaused(), ps.Session.Status)
+	}
+
+	// A paused session still acts on SIGTERM
+	ps.Pause()
+	waitForState(t, pid, "T")
+	if err := ps.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	if err := cmd.Wait(); err == nil || !strings.Contains(err.Error(), "terminated") {
+		t.Errorf("Wait = %v, want terminated", err)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for name, want := range map[string]syscall.Signal{"SIGINT": syscall.SIGINT, "term": syscall.SIGTERM, " Cont ": syscall.SIGCONT} {
+		if got, err := ParseSignal(name); err != nil || got != want {
+			t.Errorf("ParseSignal(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseSignal("SIGKILL"); err == nil {
+		t.Error("SIGKILL should not be accepted")
+	}
+}
@@ -0,0 +1,33 @@
+package terminal
+
+import (
+	"github.com/user/claude-manager/domains/process"
+)
+
+// Processes returns the session's process tree and records its total
+// resource usage on the Session
+func (ps *PTYSession) Processes() ([]*process.Process, process.Usage, error) {
+	ps.Mu.RLock()
+	agent := ps.Agent
+	finished := ps.Session.Finished()
+	ps.Mu.RUnlock()
+	if agent == nil || finished {
+		return nil, process.Usage{}, ErrNotRunning
+	}
+
+	procs, usage, err := ps.usage.Tree(agent.PID())
+	if err != nil {
+		return nil, process.Usage{}, err
+	}
+
+	ps.Mu.Lock()
+	ps.Session.SetUsage(usage.CPUPercent, usage.RSS, usage.Processes)
+	ps.Mu.Unlock()
+	return procs, usage, nil
+}
+
+// UpdateUsage records the session's current resource usage on the Session
+func (ps *PTYSession) UpdateUsage() error {
+	_, _, err := ps.Processes()
+	return err
+}
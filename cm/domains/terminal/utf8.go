@@ -0,0 +1,53 @@
+package terminal
+
+import (
+	"unicode/utf8"
+)
+
+// OutputDecoder splits a raw PTY byte stream into chunks that end on UTF-8
+// character boundaries. A multi-byte character straddling two reads is held
+// back and prepended to the next chunk instead of being cut in half. Bytes
+// are never rewritten, so invalid or binary output passes through untouched.
+type OutputDecoder struct {
+	pending []byte
+}
+
+// Feed accepts the next read from the PTY and returns the bytes that are
+// ready to forward. The returned slice may alias p.
+func (d *OutputDecoder) Feed(p []byte) []byte {
+	data := p
+	if len(d.pending) > 0 {
+		data = append(d.pending, p...)
+		d.pending = nil
+	}
+
+	if n := incompleteSuffix(data); n > 0 {
+		d.pending = append([]byte(nil), data[len(data)-n:]...)
+		data = data[:len(data)-n]
+	}
+	return data
+}
+
+// Flush returns any bytes still held back, for use when the stream ends
+func (d *OutputDecoder) Flush() []byte {
+	data := d.pending
+	d.pending = nil
+	return data
+}
+
+// incompleteSuffix returns the length of a truncated multi-byte character at
+// the end of p, or 0 if p ends on a character boundary. Sequences that can
+// never become valid are not held back.
+func incompleteSuffix(p []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(p); i++ {
+		start := len(p) - i
+		if !utf8.RuneStart(p[start]) {
+			continue
+		}
+		if utf8.FullRune(p[start:]) {
+			return 0
+		}
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package terminal
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/user/claude-manager/domains/session"
+)
+
+// boxOutput resembles Claude's UI: box drawing, emoji and plain ASCII
+const boxOutput = "╭──────╮\r\n│ 🚀 ok │\r\n╰──────╯\r\n"
+
+func TestOutputDecoderSplitSequences(t *testing.T) {
+	input := []byte(boxOutput)
+
+	// Split the stream at every possible position, including in the middle
+	// of each multi-byte character
+	for split := 1; split < len(input); split++ {
+		var decoder OutputDecoder
+		var out []byte
+
+		for _, chunk := range [][]byte{input[:split], input[split:]} {
+			data := decoder.Feed(chunk)
+			if !utf8.Valid(data) {
+				t.Fatalf("split at %d: chunk %q is not valid UTF-8", split, data)
+			}
+			out = append(out, data...)
+		}
+		out = append(out, decoder.Flush()...)
+
+		if !bytes.Equal(out, input) {
+			t.Fatalf("split at %d: got %q, want %q", split, out, input)
+		}
+	}
+}
+
+func TestOutputDecoderByteAtATime(t *testing.T) {
+	var decoder OutputDecoder
+	var out []byte
+
+	for _, b := range []byte(boxOutput) {
+		data := decoder.Feed([]byte{b})
+		if !utf8.Valid(data) {
+			t.Fatalf("chunk %q is not valid UTF-8", data)
+		}
+		out = append(out, data...)
+	}
+
+	if string(out) != boxOutput {
+		t.Errorf("got %q, want %q", out, boxOutput)
+	}
+}
+
+func TestOutputDecoderPassesInvalidBytes(t *testing.T) {
+	var decoder OutputDecoder
+
+	// A lone continuation byte and a truncated sequence followed by ASCII
+	// can never become valid, so they must not be held back or rewritten
+	input := []byte{'a', 0x80, 0xe2, 0x94, 'b'}
+	if got := decoder.Feed(input); !bytes.Equal(got, input) {
+		t.Errorf("Feed(%q) = %q, want input unchanged", input, got)
+	}
+	if got := decoder.Flush(); len(got) != 0 {
+		t.Errorf("Flush() = %q, want nothing pending", got)
+	}
+
+	// A truncated sequence at the end of the stream is flushed as-is
+	if got := decoder.Feed([]byte{'c', 0xf0, 0x9f}); string(got) != "c" {
+		t.Errorf("Feed() = %q, want %q", got, "c")
+	}
+	if got := decoder.Flush(); !bytes.Equal(got, []byte{0xf0, 0x9f}) {
+		t.Errorf("Flush() = %q, want truncated sequence", got)
+	}
+}
+
+func TestOutputPipelineBinaryFrames(t *testing.T) {
+	ps, err := NewPTYSession("test", session.NewSession("test", "/tmp", "main"))
+	if err != nil {
+		t.Fatalf("NewPTYSession: %v", err)
+	}
+
+	upgrader := websocket.Upgrader{}
+	attached := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		ps.AddClient(conn, AttachOptions{})
+		close(attached)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	<-attached
+
+	// Feed the output through the decoder in reads that cut every
+	// multi-byte character, as a 1 KB PTY read might
+	var decoder OutputDecoder
+	input := []byte(boxOutput)
+	for i := 0; i < len(input); i += 2 {
+		end := i + 2
+		if end > len(input) {
+			end = len(input)
+		}
+		if data := decoder.Feed(input[i:end]); len(data) > 0 {
+			ps.BroadcastToClients(data)
+		}
+	}
+
+	var out []byte
+	var next int64
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(out) < len(input) {
+		messageType, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if messageType == websocket.TextMessage {
+			// Control messages such as roles are interleaved with output
+			continue
+		}
+
+		offset, data, err := ParseOutputFrame(frame)
+		if err != nil {
+			t.Fatalf("ParseOutputFrame: %v", err)
+		}
+		if offset != next {
+			t.Fatalf("frame offset = %d, want %d", offset, next)
+		}
+		if !utf8.Valid(data) {
+			t.Fatalf("frame %q is not valid UTF-8", data)
+		}
+		next = offset + int64(len(data))
+		out = append(out, data...)
+	}
+
+	if string(out) != boxOutput {
+		t.Errorf("got %q, want %q", out, boxOutput)
+	}
+}
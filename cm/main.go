@@ -3,46 +3,52 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
-	"unicode/utf8"
 
-	"github.com/creack/pty"
 	"github.com/gorilla/websocket"
-	
+
+	"github.com/user/claude-manager/domains/approval"
+	"github.com/user/claude-manager/domains/attention"
+	"github.com/user/claude-manager/domains/automation"
+	"github.com/user/claude-manager/domains/environment"
+	"github.com/user/claude-manager/domains/holder"
+	"github.com/user/claude-manager/domains/limits"
+	"github.com/user/claude-manager/domains/process"
+	"github.com/user/claude-manager/domains/profile"
+	"github.com/user/claude-manager/domains/recording"
 	"github.com/user/claude-manager/domains/session"
 	"github.com/user/claude-manager/domains/terminal"
 )
 
 const VERSION = "2.0.0-web"
 
-
-
-// SessionManager manages all active sessions
-type SessionManager struct {
-	sessions map[string]*terminal.PTYSession
-	mu       sync.RWMutex
-}
-
 var (
-	sessionManager = &SessionManager{
-		sessions: make(map[string]*terminal.PTYSession),
-	}
-	sessionsManager *session.Manager // Domain-based session manager
-	sessionHandler  *session.Handler // Domain-based session handler
-	upgrader = websocket.Upgrader{
+	registry         = terminal.NewManager() // every session and its terminal
+	sessionHandler   *session.Handler        // Domain-based session handler
+	recordingHandler *recording.Handler      // Recording list and playback handler
+	approvalManager  *approval.Manager       // Permission prompts awaiting an answer
+	approvalHandler  *approval.Handler       // Permission gate API
+	profileManager   *profile.Manager        // Agents sessions can be started with
+	profileHandler   *profile.Handler        // Agent profile API
+	holdersDir       string                  // where held sessions' sockets live, if sessions are held
+	sessionStore     *session.Store          // where sessions are kept across restarts, if they are
+	upgrader         = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
 			return true // Allow all origins for development
 		},
@@ -51,21 +57,52 @@ var (
 
 func main() {
 	var (
-		serve   = flag.Bool("serve", false, "Start web server mode")
-		port    = flag.Int("port", 8080, "Web server port")
-		version = flag.Bool("version", false, "Show version")
+		serve      = flag.Bool("serve", false, "Start web server mode")
+		port       = flag.Int("port", 8080, "Web server port")
+		version    = flag.Bool("version", false, "Show version")
+		scrollback = flag.Int("scrollback", terminal.DefaultScrollbackSize, "Bytes of output history kept per session")
+		recordings = flag.String("recordings", defaultRecordingsDir(), "Directory for asciicast session recordings")
+		profiles   = flag.String("profiles", defaultProfilesFile(), "JSON file of agent profiles")
+		holders    = flag.String("holders", defaultHoldersDir(), "Directory for the processes that keep sessions running across server restarts; empty to run sessions in the server")
+		hold       = flag.String("hold", "", "Hold a session's terminal, listening on this socket (used by the server)")
+		state      = flag.String("state", defaultStateFile(), "JSON file sessions are kept in across server restarts; empty to forget them")
 	)
 	flag.Parse()
 
-	// Initialize domain managers
-	sessionsManager = session.NewManager()
-	sessionHandler = session.NewHandler(sessionsManager)
-
 	if *version {
 		fmt.Printf("Claude Manager v%s (Web Terminal Edition)\n", VERSION)
 		return
 	}
 
+	if *hold != "" {
+		if err := holder.Serve(*hold, os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("Holder failed: %v", err)
+		}
+		return
+	}
+
+	terminal.DefaultScrollbackSize = *scrollback
+	recording.DefaultDir = *recordings
+
+	// Initialize domain managers
+	sessionHandler = session.NewHandler(registry.Sessions)
+	recordingHandler = recording.NewHandler(&upgrader)
+	approvalManager = approval.NewManager()
+	approvalHandler = approval.NewHandler(approvalManager, writeSessionInput)
+	profileManager = profile.NewManager()
+	profileHandler = profile.NewHandler(profileManager)
+	if err := profileManager.Load(*profiles); err != nil {
+		log.Fatalf("Failed to load profiles: %v", err)
+	}
+
+	if *holders != "" {
+		holdersDir = *holders
+		terminal.Launch = launchHeld
+	}
+	if *state != "" {
+		sessionStore = session.NewStore(*state)
+	}
+
 	if *serve {
 		log.Printf("Starting Claude Manager Web Server on port %d", *port)
 		startWebServer(*port)
@@ -84,6 +121,13 @@ func startWebServer(port int) {
 		log.Fatalf("Failed to create web directory: %v", err)
 	}
 
+	// Bring back the sessions a previous run knew about, taking over those
+	// it left running
+	restoreSessions()
+	go watchSessions()
+	go monitorStore()
+	go monitorBranches()
+
 	// Set up HTTP routes
 	http.HandleFunc("/", handleHome)
 	http.HandleFunc("/favicon.ico", handleFavicon)
@@ -91,10 +135,18 @@ func startWebServer(port int) {
 	http.HandleFunc("/api/sessions", sessionHandler.HandleSessions)
 	http.HandleFunc("/api/sessions/create", handleCreateSession)
 	http.HandleFunc("/api/sessions/kill", handleKillSession)
+	http.HandleFunc("/api/sessions/", handleSessionAction)
 	http.HandleFunc("/api/directories", handleDirectories)
 	http.HandleFunc("/api/git-repos", handleGitRepos)
 	http.HandleFunc("/ws/", handleWebSocket)
-	// Determine web directory path based on go.mod presence  
+	http.HandleFunc("/api/recordings", recordingHandler.HandleRecordings)
+	http.HandleFunc("/playback/", handlePlaybackPage)
+	http.HandleFunc("/ws/playback/", recordingHandler.HandlePlayback)
+	http.HandleFunc("/api/events", handleEvents)
+	http.HandleFunc("/api/approvals", approvalHandler.HandleApprovals)
+	http.HandleFunc("/api/approvals/", approvalHandler.HandleApproval)
+	http.HandleFunc("/api/profiles", profileHandler.HandleProfiles)
+	// Determine web directory path based on go.mod presence
 	webStaticDir := "web/static/"
 	if _, err := os.Stat("go.mod"); err != nil {
 		webStaticDir = "cm/web/static/"
@@ -102,10 +154,17 @@ func startWebServer(port int) {
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(webStaticDir))))
 
 	// Create server with graceful shutdown
+	// Requests' contexts end when shutdown begins, closing event streams
+	// that would otherwise hold it up
+	serving, stopServing := context.WithCancel(context.Background())
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
 		Handler: nil,
+		BaseContext: func(net.Listener) context.Context {
+			return serving
+		},
 	}
+	server.RegisterOnShutdown(stopServing)
 
 	// Handle graceful shutdown
 	go func() {
@@ -114,16 +173,29 @@ func startWebServer(port int) {
 		<-sigChan
 
 		log.Println("Shutting down server...")
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
 
-		// Close all PTY sessions
-		sessionManager.mu.Lock()
-		for _, session := range sessionManager.sessions {
-			session.Cleanup()
+		// Leave held sessions running for the next run to adopt, and tear
+		// down the rest in parallel, so shutdown waits for at most one
+		// grace period
+		var wg sync.WaitGroup
+		for _, session := range registry.List() {
+			wg.Add(1)
+			go func(session *terminal.PTYSession) {
+				defer wg.Done()
+				if err := session.Detach(); !errors.Is(err, terminal.ErrNotDetachable) {
+					return
+				}
+				if err := session.Kill("server shutdown"); err != nil && !errors.Is(err, terminal.ErrNotRunning) {
+					log.Printf("Failed to tear down session %s: %v", session.ID, err)
+				}
+				session.Close()
+			}(session)
 		}
-		sessionManager.mu.Unlock()
+		wg.Wait()
+		persistSessions()
 
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 		server.Shutdown(ctx)
 	}()
 
@@ -144,13 +216,14 @@ func ensureWebDirectory() error {
 		// We're not in cm directory, try cm/web
 		webDir = filepath.Join("cm", "web")
 	}
-	
+
 	staticDir := filepath.Join(webDir, "static")
 	templatesDir := filepath.Join(webDir, "templates")
 
 	// Verify that the required files exist
 	files := []string{
 		filepath.Join(templatesDir, "index.html"),
+		filepath.Join(templatesDir, "terminal.html"),
 		filepath.Join(staticDir, "app.css"),
 		filepath.Join(staticDir, "app.js"),
 	}
@@ -169,7 +242,7 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	
+
 	// Determine template path based on go.mod presence
 	templatePath := "web/templates/index.html"
 	if _, err := os.Stat("go.mod"); err != nil {
@@ -180,174 +253,52 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 
 func handleTerminal(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Terminal request: %s", r.URL.Path)
-	
+
 	// Extract session ID from URL path
 	sessionID := r.URL.Path[len("/terminal/"):]
 	log.Printf("Extracted session ID: %s", sessionID)
-	
+
 	if sessionID == "" {
 		http.Error(w, "Session ID required", http.StatusBadRequest)
 		return
 	}
 
 	// Find session info
-	sessionManager.mu.RLock()
-	ptySession, exists := sessionManager.sessions[sessionID]
-	sessionManager.mu.RUnlock()
+	ptySession, exists := registry.Get(sessionID)
 
 	if !exists {
 		http.Error(w, "Session not found", http.StatusNotFound)
 		return
 	}
 
-	// Prepare template data
-	data := struct {
-		SessionID   string
-		SessionName string
-		SessionPath string
-	}{
+	info := ptySession.Snapshot()
+	renderTerminalPage(w, terminalPageData{
 		SessionID:   sessionID,
-		SessionName: ptySession.Session.Name,
-		SessionPath: ptySession.Session.Path,
-	}
-
-	// Parse and execute template
-	tmpl := `<!DOCTYPE html>
-<html>
-<head>
-    <title>{{.SessionName}} - Terminal</title>
-    <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/xterm@5.3.0/css/xterm.css" />
-    <style>
-        body {
-            margin: 0;
-            padding: 20px;
-            background: #1e1e1e;
-            color: white;
-            font-family: Arial, sans-serif;
-        }
-        #terminal {
-            margin: 20px 0;
-            border: 1px solid #444;
-        }
-        .header {
-            background: #2d2d2d;
-            padding: 10px;
-            border-radius: 4px 4px 0 0;
-            border: 1px solid #444;
-            border-bottom: none;
-            display: flex;
-            justify-content: space-between;
-            align-items: center;
-        }
-        .header h3 {
-            margin: 0;
-            color: #00ff00;
-        }
-        .back-btn {
-            background: #007acc;
-            color: white;
-            border: none;
-            padding: 5px 10px;
-            border-radius: 4px;
-            cursor: pointer;
-        }
-        .back-btn:hover {
-            background: #005999;
-        }
-    </style>
-</head>
-<body>
-    <div class="header">
-        <h3>{{.SessionName}} - {{.SessionPath}}</h3>
-        <button class="back-btn" onclick="window.location.href='/'">Back to Manager</button>
-    </div>
-    <div id="terminal"></div>
-    
-    <script src="https://cdn.jsdelivr.net/npm/xterm@5.3.0/lib/xterm.js"></script>
-    <script>
-        let terminal;
-        let websocket;
-
-        // Initialize terminal - exact same as our working test
-        document.addEventListener('DOMContentLoaded', function() {
-            terminal = new Terminal({
-                cursorBlink: true,
-                theme: {
-                    background: '#1e1e1e',
-                    foreground: '#ffffff',
-                    cursor: '#00ff00',
-                    black: '#000000',
-                    red: '#cd3131',
-                    green: '#0dbc79',
-                    yellow: '#e5e510',
-                    blue: '#2472c8',
-                    magenta: '#bc3fbc',
-                    cyan: '#11a8cd',
-                    white: '#e5e5e5',
-                    brightBlack: '#666666',
-                    brightRed: '#f14c4c',
-                    brightGreen: '#23d18b',
-                    brightYellow: '#f5f543',
-                    brightBlue: '#3b8eea',
-                    brightMagenta: '#d670d6',
-                    brightCyan: '#29b8db',
-                    brightWhite: '#e5e5e5'
-                },
-                fontSize: 14,
-                fontFamily: 'Consolas, "Liberation Mono", Menlo, Courier, monospace'
-            });
-
-            terminal.open(document.getElementById('terminal'));
-            terminal.write('Terminal initialized successfully!\r\n');
-            terminal.write('Connecting to Claude session...\r\n');
-            
-            // Connect WebSocket - same as working test
-            const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-            const wsUrl = protocol + '//' + window.location.host + '/ws/{{.SessionID}}';
-            
-            websocket = new WebSocket(wsUrl);
-            
-            websocket.onopen = () => {
-                terminal.write('\r\n✅ Connected to Claude session!\r\n');
-                
-                // Send terminal input to WebSocket
-                terminal.onData(data => {
-                    if (websocket.readyState === WebSocket.OPEN) {
-                        websocket.send(data);
-                    }
-                });
-            };
-            
-            websocket.onmessage = (event) => {
-                terminal.write(event.data);
-            };
-            
-            websocket.onclose = () => {
-                terminal.write('\r\n⚠️ Connection closed\r\n');
-            };
-            
-            websocket.onerror = (error) => {
-                terminal.write('\r\n❌ Connection error\r\n');
-            };
-            
-            // Focus terminal
-            setTimeout(() => terminal.focus(), 100);
-        });
-
-        // Listen for messages from parent window (for control buttons)
-        window.addEventListener('message', function(event) {
-            if (event.data.type === 'sendInput' && websocket && websocket.readyState === WebSocket.OPEN) {
-                websocket.send(event.data.data);
-                terminal.write(event.data.data);
-            }
-        });
-    </script>
-</body>
-</html>`
-
-	// Execute template
-	t, err := template.New("terminal").Parse(tmpl)
+		SessionName: info.Name,
+		SessionPath: info.Path,
+	})
+}
+
+// terminalPageData fills the terminal page template. The same page serves
+// live sessions and recording playback.
+type terminalPageData struct {
+	SessionID   string
+	SessionName string
+	SessionPath string
+	Playback    bool
+	Recording   string
+}
+
+func renderTerminalPage(w http.ResponseWriter, data terminalPageData) {
+	// Determine template path based on go.mod presence
+	templatePath := "web/templates/terminal.html"
+	if _, err := os.Stat("go.mod"); err != nil {
+		templatePath = "cm/web/templates/terminal.html"
+	}
+
+	t, err := template.ParseFiles(templatePath)
 	if err != nil {
+		log.Printf("Failed to parse terminal template: %v", err)
 		http.Error(w, "Template error", http.StatusInternalServerError)
 		return
 	}
@@ -356,6 +307,29 @@ func handleTerminal(w http.ResponseWriter, r *http.Request) {
 	t.Execute(w, data)
 }
 
+// handlePlaybackPage renders the terminal page for /playback/{recording}
+func handlePlaybackPage(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[len("/playback/"):]
+	path, err := recording.ResolvePath(recording.DefaultDir, name)
+	if err != nil {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	cast, err := recording.ReadFile(path)
+	if err != nil {
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+
+	renderTerminalPage(w, terminalPageData{
+		SessionName: cast.Header.Title,
+		SessionPath: name,
+		Playback:    true,
+		Recording:   name,
+	})
+}
+
 func handleFavicon(w http.ResponseWriter, r *http.Request) {
 	// Simple SVG favicon
 	favicon := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16">
@@ -366,22 +340,60 @@ func handleFavicon(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(favicon))
 }
 
-
 func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req session.CreateRequest
+	// An optional expect script runs once the session starts
+	var body struct {
+		session.CreateRequest
+		Expect *automation.Script `json:"expect"`
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
+	req := body.CreateRequest
+	if err := req.Limits.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid limits: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := req.Restart.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid restart policy: %v", err), http.StatusBadRequest)
+		return
+	}
+	if body.Expect != nil {
+		if err := body.Expect.Compile(); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid expect script: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	agent, err := profileManager.Select(req.Profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.ClaudeOptions.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid Claude options: %v", err), http.StatusBadRequest)
+		return
+	}
+	if _, err := agent.CommandLine(req.ClaudeOptions); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Env files are read from the repository, as cw copies .env from it
+	env, envOptions, err := environment.Build(os.Environ(), agent.Environment(), req.EnvOptions, req.RepoPath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid environment: %v", err), http.StatusBadRequest)
+		return
+	}
+	req.EnvOptions = envOptions
 
 	var workingPath string
-	var err error
 
 	if req.UseWorktree && isGitRepository(req.RepoPath) {
 		// Create worktree: projects/repo-name-sessionname
@@ -396,19 +408,23 @@ func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		workingPath = req.RepoPath
 	}
 
-	session, err := createPTYSession(req.Name, workingPath)
+	session, err := createPTYSession(req, workingPath, agent, env, body.Expect)
 	if err != nil {
 		log.Printf("Failed to create session: %v", err)
 		// Clean up worktree if we created one
 		if req.UseWorktree && workingPath != req.RepoPath {
 			cleanupWorktree(workingPath)
 		}
+		if errors.Is(err, terminal.ErrExists) {
+			http.Error(w, "Session already exists", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(session.Session)
+	json.NewEncoder(w).Encode(session.Snapshot())
 }
 
 func createWorktreeForSession(repoPath, sessionName, branchName, baseBranch string) (string, error) {
@@ -439,7 +455,7 @@ func createWorktreeForSession(repoPath, sessionName, branchName, baseBranch stri
 	checkCmd := exec.Command("git", "branch", "--list", cleanBranchName)
 	checkCmd.Dir = repoPath
 	checkOutput, _ := checkCmd.Output()
-	
+
 	var cmd *exec.Cmd
 	if len(strings.TrimSpace(string(checkOutput))) > 0 {
 		// Branch exists, use existing branch
@@ -449,7 +465,7 @@ func createWorktreeForSession(repoPath, sessionName, branchName, baseBranch stri
 		// Branch doesn't exist, create new branch
 		cmd = exec.Command("git", "worktree", "add", "-b", cleanBranchName, worktreePath, baseBranch)
 	}
-	
+
 	cmd.Dir = repoPath
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -543,178 +559,726 @@ func handleKillSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessionManager.mu.Lock()
-	ptySession, exists := sessionManager.sessions[req.SessionID]
-	if exists {
-		delete(sessionManager.sessions, req.SessionID)
-	}
-	sessionManager.mu.Unlock()
+	ptySession, exists := registry.Get(req.SessionID)
 
-	// Also remove from domain manager
-	sessionsManager.Remove(req.SessionID)
+	if !exists {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
 
-	if exists {
-		ptySession.Cleanup()
+	// The session stays listed as killed until it is dismissed
+	by := req.By
+	if by == "" {
+		by = "user"
+	}
+	err := ptySession.Kill(by)
+	if errors.Is(err, terminal.ErrNotRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to tear down session %s: %v", req.SessionID, err)
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-type DirectoryInfo struct {
-	Name      string `json:"name"`
-	Path      string `json:"path"`
-	IsDir     bool   `json:"isDir"`
-	IsGitRepo bool   `json:"isGitRepo"`
-}
+// eventsKeepalive is how often an idle event stream is sent a comment, so
+// that it is not timed out along the way
+const eventsKeepalive = 25 * time.Second
 
-func handleDirectories(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Query().Get("path")
-	if path == "" {
-		// Start from user's home directory
-		if homeDir, err := os.UserHomeDir(); err == nil {
-			path = homeDir
-		} else {
-			path = "/"
-		}
+// eventsEpoch tells this run's event IDs from an earlier run's, which
+// numbered its events from 1 too
+var eventsEpoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// handleEvents handles GET /api/events, a Server-Sent Events stream of
+// session events. A stream starts with a "sessions" event listing every
+// session. A client resuming with Last-Event-ID is sent the events it
+// missed instead, or the list again if they are no longer retained.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
 	}
 
-	// Clean and validate path
-	path = filepath.Clean(path)
+	// Subscribe before catching up, so that no event falls in between
+	bus := registry.Events()
+	events, unsubscribe := bus.Subscribe(256)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, "retry: 2000\n\n")
+
+	// Events up to sent are already reflected in what the client has
+	var sent uint64
+	var missed []terminal.Event
+	resumed := false
+	if last, ok := parseEventID(r.Header.Get("Last-Event-ID")); ok {
+		missed, resumed = bus.Since(last)
+		sent = last
+	}
+	if !resumed {
+		// Events published while the list is taken may be sent again
+		// after it; applying them twice does no harm
+		sent = bus.Last()
+		writeEvent(w, sent, "sessions", registry.Sessions())
+	}
+	for _, event := range missed {
+		writeEvent(w, event.ID, string(event.Type), event)
+		sent = event.ID
+	}
+	flusher.Flush()
 
-	entries, err := os.ReadDir(path)
+	keepalive := time.NewTicker(eventsKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// The client fell behind; it reconnects and resumes
+				return
+			}
+			if event.ID <= sent {
+				continue
+			}
+			if err := writeEvent(w, event.ID, string(event.Type), event); err != nil {
+				return
+			}
+			sent = event.ID
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes a Server-Sent Event carrying data as JSON
+func writeEvent(w http.ResponseWriter, id uint64, kind string, data interface{}) error {
+	payload, err := json.Marshal(data)
 	if err != nil {
-		log.Printf("Cannot read directory %s: %v", path, err)
-		http.Error(w, fmt.Sprintf("Cannot read directory: %v", err), http.StatusBadRequest)
-		return
+		return err
 	}
+	_, err = fmt.Fprintf(w, "id: %s-%d\nevent: %s\ndata: %s\n\n", eventsEpoch, id, kind, payload)
+	return err
+}
 
-	var items []DirectoryInfo
+// parseEventID returns the bus event ID in a Last-Event-ID sent back by a
+// client, if it was issued by this run
+func parseEventID(header string) (uint64, bool) {
+	epoch, id, found := strings.Cut(header, "-")
+	if !found || epoch != eventsEpoch {
+		return 0, false
+	}
+	last, err := strconv.ParseUint(id, 10, 64)
+	return last, err == nil
+}
 
-	// Add parent directory if not at filesystem root
-	if path != "/" {
-		parentPath := filepath.Dir(path)
-		items = append(items, DirectoryInfo{
-			Name:  "..",
-			Path:  parentPath,
-			IsDir: true,
-		})
+// handleSessionAction routes /api/sessions/{id}/{action} requests
+func handleSessionAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sessions/"), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
 	}
+	sessionID, action := parts[0], parts[1]
 
-	// Add all directories (and optionally files)
-	for _, entry := range entries {
-		fullPath := filepath.Join(path, entry.Name())
+	ptySession, exists := registry.Get(sessionID)
 
-		// Skip hidden files/directories unless specifically requested
-		if strings.HasPrefix(entry.Name(), ".") && entry.Name() != ".." {
-			continue
-		}
+	if !exists {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
 
-		if entry.IsDir() {
-			isGitRepo := isGitRepository(fullPath)
+	switch action {
+	case "clients":
+		handleSessionClients(w, r, ptySession)
+	case "driver":
+		handleSessionDriver(w, r, ptySession)
+	case "recording":
+		handleSessionRecording(w, r, ptySession)
+	case "screen":
+		handleSessionScreen(w, r, ptySession)
+	case "input":
+		handleSessionInput(w, r, ptySession)
+	case "expect":
+		handleSessionExpect(w, r, ptySession)
+	case "signal", "pause", "resume":
+		handleSessionSignal(w, r, ptySession, action)
+	case "processes":
+		handleSessionProcesses(w, r, ptySession)
+	case "relaunch":
+		handleSessionRelaunch(w, r, ptySession)
+	case "dismiss":
+		handleSessionDismiss(w, r, ptySession)
+	default:
+		http.NotFound(w, r)
+	}
+}
 
-			items = append(items, DirectoryInfo{
-				Name:      entry.Name(),
-				Path:      fullPath,
-				IsDir:     true,
-				IsGitRepo: isGitRepo,
-			})
-		}
+// handleSessionClients handles GET /api/sessions/{id}/clients
+func handleSessionClients(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
+	writeSessionClients(w, ptySession)
+}
+
+func writeSessionClients(w http.ResponseWriter, ptySession *terminal.PTYSession) {
 	response := struct {
-		CurrentPath string          `json:"currentPath"`
-		Items       []DirectoryInfo `json:"items"`
+		Driver  string                `json:"driver"`
+		Clients []terminal.ClientInfo `json:"clients"`
 	}{
-		CurrentPath: path,
-		Items:       items,
+		Driver:  ptySession.Driver(),
+		Clients: ptySession.ListClients(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-type GitRepoInfo struct {
-	Name          string   `json:"name"`
-	Path          string   `json:"path"`
-	CurrentBranch string   `json:"currentBranch"`
-	Worktrees     []string `json:"worktrees"`
-}
+// handleSessionDriver handles POST /api/sessions/{id}/driver, handing the
+// driver role to another attached client
+func handleSessionDriver(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-func handleGitRepos(w http.ResponseWriter, r *http.Request) {
-	searchPath := r.URL.Query().Get("path")
-	if searchPath == "" {
-		// Default to user's home directory
-		if homeDir, err := os.UserHomeDir(); err == nil {
-			searchPath = homeDir
-		} else {
-			searchPath = "/"
-		}
+	var req struct {
+		ClientID string `json:"clientId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ClientID == "" {
+		http.Error(w, "Client ID is required", http.StatusBadRequest)
+		return
 	}
 
-	repos := findGitRepositories(searchPath)
+	if err := ptySession.SetDriver(req.ClientID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 
-	var gitRepos []GitRepoInfo
-	for _, repoPath := range repos {
-		branch := getGitBranch(repoPath)
-		worktrees := getGitWorktrees(repoPath)
+	writeSessionClients(w, ptySession)
+}
 
-		gitRepos = append(gitRepos, GitRepoInfo{
-			Name:          filepath.Base(repoPath),
-			Path:          repoPath,
-			CurrentBranch: branch,
-			Worktrees:     worktrees,
-		})
+// handleSessionScreen handles GET /api/sessions/{id}/screen. The visible
+// screen is returned as plain text, or with format=json as lines of
+// attributed spans.
+func handleSessionScreen(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	response := struct {
-		SearchPath string        `json:"searchPath"`
-		Repos      []GitRepoInfo `json:"repos"`
-	}{
-		SearchPath: searchPath,
-		Repos:      gitRepos,
+	switch r.URL.Query().Get("format") {
+	case "", "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, ptySession.ScreenText())
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ptySession.ScreenSnapshot())
+	default:
+		http.Error(w, "Unknown format", http.StatusBadRequest)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
 }
 
-func isGitRepository(path string) bool {
-	gitDir := filepath.Join(path, ".git")
-	if info, err := os.Stat(gitDir); err == nil {
-		return info.IsDir()
+// Defaults for POST /api/sessions/{id}/input when waiting for output
+const (
+	defaultInputSettle  = 500 * time.Millisecond
+	defaultInputTimeout = 10 * time.Second
+	maxInputTimeout     = 5 * time.Minute
+)
+
+// handleSessionInput handles POST /api/sessions/{id}/input. It types text,
+// raw bytes and named keys into the session and, if asked to wait, returns
+// the output produced until the session goes quiet.
+func handleSessionInput(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	return false
-}
 
-func findGitRepositories(baseDir string) []string {
-	var repos []string
+	var req session.InputRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
 
-	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+	input := append([]byte(req.Text), req.Raw...)
+	for _, key := range req.Keys {
+		seq, err := terminal.KeySequence(key)
 		if err != nil {
-			return nil // Skip errors
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		input = append(input, seq...)
+	}
+	if len(input) == 0 {
+		http.Error(w, "No input given", http.StatusBadRequest)
+		return
+	}
 
-		if info.IsDir() && info.Name() == ".git" {
-			repoPath := filepath.Dir(path)
-			repos = append(repos, repoPath)
-			return filepath.SkipDir // Don't descend into .git directories
-		}
+	offset := ptySession.OutputOffset()
+	if err := ptySession.WriteInput(input); err != nil {
+		log.Printf("Failed to write input to session %s: %v", ptySession.ID, err)
+		http.Error(w, fmt.Sprintf("Failed to write input: %v", err), http.StatusConflict)
+		return
+	}
 
-		// Skip deep nesting
-		depth := strings.Count(strings.TrimPrefix(path, baseDir), string(filepath.Separator))
-		if depth > 3 {
-			return filepath.SkipDir
+	response := struct {
+		Written   int    `json:"written"`
+		Output    string `json:"output"`
+		Offset    int64  `json:"offset"`    // where the returned output starts
+		Truncated bool   `json:"truncated"` // output before Offset was lost
+		Settled   bool   `json:"settled"`   // false if the wait timed out
+	}{Written: len(input), Offset: offset}
+
+	if req.Wait {
+		settle := defaultInputSettle
+		if req.Settle > 0 {
+			settle = time.Duration(req.Settle) * time.Millisecond
+		}
+		timeout := defaultInputTimeout
+		if req.Timeout > 0 {
+			timeout = min(time.Duration(req.Timeout)*time.Millisecond, maxInputTimeout)
 		}
 
-		return nil
-	})
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		response.Settled = ptySession.WaitForQuiet(ctx, settle) == nil
+		cancel()
 
-	if err != nil {
-		log.Printf("Error walking directory %s: %v", baseDir, err)
+		output, from := ptySession.OutputSince(offset)
+		response.Output = string(output)
+		response.Truncated = from > offset
+		response.Offset = from
 	}
 
-	return repos
-}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionRelaunch handles POST /api/sessions/{id}/relaunch, which
+// starts a finished session's agent again in the same directory and with
+// the same options
+func handleSessionRelaunch(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := killOrphan(ptySession); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to stop the agent left running: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := ptySession.Relaunch(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if _, err := launchSession(ptySession, nil); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to relaunch session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ptySession.Snapshot())
+}
+
+// handleSessionDismiss handles POST /api/sessions/{id}/dismiss, which
+// removes a finished session
+func handleSessionDismiss(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ptySession.Mu.RLock()
+	finished := ptySession.Session.Finished()
+	ptySession.Mu.RUnlock()
+	if !finished {
+		http.Error(w, "Session is still running; kill it first", http.StatusConflict)
+		return
+	}
+	if err := killOrphan(ptySession); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to stop the agent left running: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	registry.Remove(ptySession.ID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleSessionProcesses handles GET /api/sessions/{id}/processes, the tree
+// of processes running in the session and the resources they use
+func handleSessionProcesses(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	procs, usage, err := ptySession.Processes()
+	if errors.Is(err, terminal.ErrNotRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read processes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if procs == nil {
+		procs = []*process.Process{}
+	}
+
+	response := struct {
+		SessionID string             `json:"session_id"`
+		Usage     process.Usage      `json:"usage"`
+		Processes []*process.Process `json:"processes"`
+	}{
+		SessionID: ptySession.ID,
+		Usage:     usage,
+		Processes: procs,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionSignal handles POST /api/sessions/{id}/signal with a body
+// such as {"signal": "SIGINT"}, and POST /api/sessions/{id}/pause and
+// /resume, which stop and continue the session's processes
+func handleSessionSignal(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession, action string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var sig syscall.Signal
+	switch action {
+	case "pause":
+		sig = syscall.SIGSTOP
+	case "resume":
+		sig = syscall.SIGCONT
+	default:
+		var req session.SignalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if sig, err = terminal.ParseSignal(req.Signal); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := ptySession.Signal(sig); err != nil {
+		log.Printf("Failed to send %v to session %s: %v", sig, ptySession.ID, err)
+		if errors.Is(err, terminal.ErrNotRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to send signal: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+	log.Printf("Sent %v to session %s", sig, ptySession.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ptySession.Snapshot())
+}
+
+// handleSessionExpect handles POST /api/sessions/{id}/expect. The script's
+// steps wait for output patterns and send input in turn; the response
+// reports each step's outcome once the script finishes.
+func handleSessionExpect(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var script automation.Script
+	if err := json.NewDecoder(r.Body).Decode(&script); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := script.Compile(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := script.Run(r.Context(), ptySession)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// runStartupScript runs the script given when a session was created,
+// matching against everything the session has printed
+func runStartupScript(ptySession *terminal.PTYSession, script *automation.Script) {
+	result := script.RunFrom(context.Background(), ptySession, 0)
+	if !result.Success {
+		last := result.Steps[len(result.Steps)-1]
+		log.Printf("Startup script for session %s failed at step %d: %s", ptySession.ID, last.Step, last.Error)
+		return
+	}
+	log.Printf("Startup script for session %s finished in %.1fs", ptySession.ID, result.Elapsed)
+}
+
+// handleSessionRecording handles /api/sessions/{id}/recording. GET reports
+// the active recording; POST starts or stops one.
+func handleSessionRecording(w http.ResponseWriter, r *http.Request, ptySession *terminal.PTYSession) {
+	switch r.Method {
+	case "GET":
+	case "POST":
+		var req session.RecordingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		if req.Enabled {
+			if err := startSessionRecording(ptySession, req.Input); err != nil {
+				log.Printf("Failed to start recording for session %s: %v", ptySession.ID, err)
+				http.Error(w, fmt.Sprintf("Failed to start recording: %v", err), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			if err := ptySession.StopRecording(); err != nil {
+				log.Printf("Failed to finish recording for session %s: %v", ptySession.ID, err)
+			}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := struct {
+		Active    bool   `json:"active"`
+		Recording string `json:"recording,omitempty"`
+	}{
+		Active:    ptySession.IsRecording(),
+		Recording: ptySession.RecordingPath(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// startSessionRecording begins an asciicast recording of a session
+func startSessionRecording(ptySession *terminal.PTYSession, captureInput bool) error {
+	size := ptySession.GetSize()
+	ptySession.Mu.RLock()
+	name := ptySession.Session.Name
+	ptySession.Mu.RUnlock()
+	recorder, err := recording.NewRecorder(recording.DefaultDir, ptySession.ID, name, size.Rows, size.Cols, captureInput)
+	if err != nil {
+		return err
+	}
+
+	ptySession.StartRecording(recorder, recorder.Path)
+	log.Printf("Recording session %s to %s", ptySession.ID, recorder.Path)
+	return nil
+}
+
+// defaultProfilesFile returns ~/.claude-manager/profiles.json
+func defaultProfilesFile() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "profiles.json"
+	}
+	return filepath.Join(homeDir, ".claude-manager", "profiles.json")
+}
+
+// defaultHoldersDir returns ~/.claude-manager/holders
+func defaultHoldersDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "holders"
+	}
+	return filepath.Join(homeDir, ".claude-manager", "holders")
+}
+
+// defaultStateFile returns ~/.claude-manager/sessions.json
+func defaultStateFile() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "sessions.json"
+	}
+	return filepath.Join(homeDir, ".claude-manager", "sessions.json")
+}
+
+// defaultRecordingsDir returns ~/.claude-manager/recordings
+func defaultRecordingsDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "recordings"
+	}
+	return filepath.Join(homeDir, ".claude-manager", "recordings")
+}
+
+type DirectoryInfo struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	IsDir     bool   `json:"isDir"`
+	IsGitRepo bool   `json:"isGitRepo"`
+}
+
+func handleDirectories(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		// Start from user's home directory
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			path = homeDir
+		} else {
+			path = "/"
+		}
+	}
+
+	// Clean and validate path
+	path = filepath.Clean(path)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		log.Printf("Cannot read directory %s: %v", path, err)
+		http.Error(w, fmt.Sprintf("Cannot read directory: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var items []DirectoryInfo
+
+	// Add parent directory if not at filesystem root
+	if path != "/" {
+		parentPath := filepath.Dir(path)
+		items = append(items, DirectoryInfo{
+			Name:  "..",
+			Path:  parentPath,
+			IsDir: true,
+		})
+	}
+
+	// Add all directories (and optionally files)
+	for _, entry := range entries {
+		fullPath := filepath.Join(path, entry.Name())
+
+		// Skip hidden files/directories unless specifically requested
+		if strings.HasPrefix(entry.Name(), ".") && entry.Name() != ".." {
+			continue
+		}
+
+		if entry.IsDir() {
+			isGitRepo := isGitRepository(fullPath)
+
+			items = append(items, DirectoryInfo{
+				Name:      entry.Name(),
+				Path:      fullPath,
+				IsDir:     true,
+				IsGitRepo: isGitRepo,
+			})
+		}
+	}
+
+	response := struct {
+		CurrentPath string          `json:"currentPath"`
+		Items       []DirectoryInfo `json:"items"`
+	}{
+		CurrentPath: path,
+		Items:       items,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+type GitRepoInfo struct {
+	Name          string   `json:"name"`
+	Path          string   `json:"path"`
+	CurrentBranch string   `json:"currentBranch"`
+	Worktrees     []string `json:"worktrees"`
+}
+
+func handleGitRepos(w http.ResponseWriter, r *http.Request) {
+	searchPath := r.URL.Query().Get("path")
+	if searchPath == "" {
+		// Default to user's home directory
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			searchPath = homeDir
+		} else {
+			searchPath = "/"
+		}
+	}
+
+	repos := findGitRepositories(searchPath)
+
+	var gitRepos []GitRepoInfo
+	for _, repoPath := range repos {
+		branch := getGitBranch(repoPath)
+		worktrees := getGitWorktrees(repoPath)
+
+		gitRepos = append(gitRepos, GitRepoInfo{
+			Name:          filepath.Base(repoPath),
+			Path:          repoPath,
+			CurrentBranch: branch,
+			Worktrees:     worktrees,
+		})
+	}
+
+	response := struct {
+		SearchPath string        `json:"searchPath"`
+		Repos      []GitRepoInfo `json:"repos"`
+	}{
+		SearchPath: searchPath,
+		Repos:      gitRepos,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func isGitRepository(path string) bool {
+	gitDir := filepath.Join(path, ".git")
+	if info, err := os.Stat(gitDir); err == nil {
+		return info.IsDir()
+	}
+	return false
+}
+
+func findGitRepositories(baseDir string) []string {
+	var repos []string
+
+	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+
+		if info.IsDir() && info.Name() == ".git" {
+			repoPath := filepath.Dir(path)
+			repos = append(repos, repoPath)
+			return filepath.SkipDir // Don't descend into .git directories
+		}
+
+		// Skip deep nesting
+		depth := strings.Count(strings.TrimPrefix(path, baseDir), string(filepath.Separator))
+		if depth > 3 {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("Error walking directory %s: %v", baseDir, err)
+	}
+
+	return repos
+}
 
 func getGitWorktrees(repoPath string) []string {
 	cmd := exec.Command("git", "worktree", "list", "--porcelain")
@@ -743,9 +1307,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessionManager.mu.Lock()
-	ptySession, exists := sessionManager.sessions[sessionID]
-	sessionManager.mu.Unlock()
+	ptySession, exists := registry.Get(sessionID)
 
 	if !exists {
 		http.Error(w, "Session not found", http.StatusNotFound)
@@ -759,40 +1321,65 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	// Add client to session
-	ptySession.Mu.Lock()
-	ptySession.Clients[conn] = true
-	ptySession.Mu.Unlock()
+	// Clients resuming after a reconnect pass the offset they last saw, new
+	// ones get a repaint of the screen; mode=viewer attaches read-only,
+	// mode=driver takes control
+	query := r.URL.Query()
+	opts := terminal.AttachOptions{
+		Role: query.Get("mode"),
+		Name: query.Get("name"),
+	}
+	if resume := query.Get("offset"); resume != "" {
+		if offset, err := strconv.ParseInt(resume, 10, 64); err == nil {
+			opts.Offset = offset
+			opts.Resume = true
+		}
+	}
 
-	// Remove client when done
-	defer func() {
-		ptySession.Mu.Lock()
-		delete(ptySession.Clients, conn)
-		ptySession.Mu.Unlock()
-	}()
+	// Add client to session and remove it when done
+	client := ptySession.AddClient(conn, opts)
+	defer ptySession.RemoveClient(conn)
 
-	// Handle WebSocket messages (terminal input)
-	go func() {
-		for {
-			_, message, err := conn.ReadMessage()
-			if err != nil {
-				break
-			}
+	// Handle WebSocket messages (terminal input and control messages)
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
 
-			// Write to PTY
-			if ptySession.PTY != nil {
-				ptySession.PTY.Write(message)
+		if messageType == websocket.TextMessage {
+			if ctrl, ok := terminal.ParseControlMessage(message); ok {
+				switch ctrl.Type {
+				case terminal.MessageResize:
+					if err := ptySession.SetClientSize(conn, ctrl.Rows, ctrl.Cols); err != nil {
+						log.Printf("Resize failed for session %s: %v", sessionID, err)
+					}
+					continue
+				case terminal.MessageDriver:
+					if err := ptySession.RequestDriver(client, ctrl.ClientID); err != nil {
+						log.Printf("Driver change refused for session %s: %v", sessionID, err)
+					}
+					continue
+				case terminal.MessageInput:
+					message = []byte(ctrl.Data)
+				}
 			}
 		}
-	}()
 
+		// Only the driver's input reaches the PTY
+		if !ptySession.IsDriver(conn) {
+			continue
+		}
 
-	// Keep connection alive
-	select {}
+		// Write to PTY
+		if err := ptySession.WriteInput(message); err != nil {
+			log.Printf("Input dropped for session %s: %v", sessionID, err)
+		}
+	}
 }
 
-func createPTYSession(name, path string) (*terminal.PTYSession, error) {
-	sessionID := fmt.Sprintf("session_%d", time.Now().Unix())
+func createPTYSession(req session.CreateRequest, path string, agent *profile.Profile, env []string, script *automation.Script) (*terminal.PTYSession, error) {
+	sessionID := fmt.Sprintf("session_%d", time.Now().UnixNano())
 
 	// Check if directory exists
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -803,53 +1390,60 @@ func createPTYSession(name, path string) (*terminal.PTYSession, error) {
 	branch := getGitBranch(path)
 
 	// Create PTY session first, then start command
-	newSession := session.NewSession(name, path, branch)
+	args, err := agent.CommandLine(req.ClaudeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	newSession := session.NewSession(req.Name, path, branch)
 	newSession.ID = sessionID
+	newSession.Profile = agent.Name
+	newSession.Command = append([]string{agent.Command}, args...)
+	if !req.ClaudeOptions.IsZero() {
+		options := req.ClaudeOptions
+		newSession.Claude = &options
+	}
+	if !req.EnvOptions.IsZero() {
+		options := req.EnvOptions
+		newSession.Environment = &options
+	}
+	if !req.Restart.IsZero() {
+		policy := req.Restart
+		newSession.Restart = &policy
+	}
 
 	ptySession, err := terminal.NewPTYSession(sessionID, newSession)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create PTY session: %v", err)
 	}
+	ptySession.SetDetection(agent.Ready(), agent.Prompts(), agent.Busy())
+	if err := registry.Add(ptySession); err != nil {
+		return nil, err
+	}
 
-	// Store session in both managers
-	sessionManager.mu.Lock()
-	sessionManager.sessions[sessionID] = ptySession
-	sessionManager.mu.Unlock()
-	sessionsManager.Add(newSession)
-
-	// Start Claude Code with PTY in background
-	go func() {
-		// Try different shell first, then Claude
-		var cmd *exec.Cmd
-
-		// Check if claude command exists
-		if _, err := exec.LookPath("claude"); err == nil {
-			// Start interactive Claude session
-			log.Printf("Starting Claude session for %s", sessionID)
-			cmd = exec.Command("claude")
-		} else {
-			// Fallback to bash shell for testing
-			log.Printf("Claude not found, starting bash shell for session %s", sessionID)
-			cmd = exec.Command("bash", "-i") // Interactive bash
+	// Start recording before the process so no output is missed
+	if req.Record {
+		if err := startSessionRecording(ptySession, req.RecordInput); err != nil {
+			log.Printf("Failed to start recording for session %s: %v", sessionID, err)
 		}
+	}
 
-		cmd.Dir = path
-		cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	ptySession.SetLaunch(terminal.LaunchSpec{
+		Command: agent.Command,
+		Args:    args,
+		Env:     env,
+		Dir:     path,
+		Limits:  req.Limits,
+	})
 
-		// Create PTY
-		ptyFile, err := pty.Start(cmd)
+	// Start Claude Code with PTY in background
+	go func() {
+		log.Printf("Starting %s session for %s", agent.Name, sessionID)
+		running, err := launchSession(ptySession, script)
 		if err != nil {
-			log.Printf("Failed to start PTY for session %s: %v", sessionID, err)
-			newSession.SetStatus("error")
 			return
 		}
 
-		// Update session with PTY info
-		ptySession.PTY = ptyFile
-		ptySession.Cmd = cmd
-		newSession.PID = cmd.Process.Pid
-		newSession.SetStatus("active")
-
 		// Send welcome message and test commands to terminal
 		go func() {
 			time.Sleep(100 * time.Millisecond) // Give PTY time to initialize
@@ -858,30 +1452,407 @@ func createPTYSession(name, path string) (*terminal.PTYSession, error) {
 			welcome += fmt.Sprintf("\033[90mDirectory: %s\033[0m\r\n", newSession.Path)
 			welcome += fmt.Sprintf("\033[90mBranch: %s\033[0m\r\n", newSession.Branch)
 			welcome += "\r\n"
-			ptySession.PTY.Write([]byte(welcome))
-			
+			running.Write([]byte(welcome))
+
 			// Send a test command to trigger shell output
 			time.Sleep(200 * time.Millisecond)
-			if strings.Contains(cmd.Path, "bash") {
+			if strings.Contains(agent.Command, "bash") {
 				// For bash, send a simple command to get a prompt
-				ptySession.PTY.Write([]byte("echo 'Terminal ready. Type commands:'\r\n"))
+				running.Write([]byte("echo 'Terminal ready. Type commands:'\r\n"))
 				time.Sleep(100 * time.Millisecond)
-				ptySession.PTY.Write([]byte("pwd\r\n")) // Show current directory
+				running.Write([]byte("pwd\r\n")) // Show current directory
 			}
 		}()
+	}()
 
-		// Start output forwarder
-		go forwardPTYOutput(ptySession)
+	return ptySession, nil
+}
 
-		// Monitor process
-		go monitorPTYProcess(ptySession)
+// launchSession starts a session's agent, or starts it again, along with
+// the goroutines that serve it for as long as it runs. The script, if any,
+// is run against the new agent.
+func launchSession(pts *terminal.PTYSession, script *automation.Script) (terminal.Agent, error) {
+	agent, err := pts.Start()
+	if err != nil {
+		log.Printf("Failed to start session %s: %v", pts.ID, err)
+		return nil, err
+	}
+	if mode := pts.LimitMode(); mode != "" {
+		log.Printf("Session %s limits enforced by %s", pts.ID, mode)
+	}
 
-		log.Printf("Started session %s with PID %d", sessionID, cmd.Process.Pid)
-	}()
+	serveSession(pts, agent, script)
+	log.Printf("Started session %s with PID %d", pts.ID, agent.PID())
+	return agent, nil
+}
+
+// serveSession starts the goroutines that serve a session's agent for as
+// long as it runs
+func serveSession(pts *terminal.PTYSession, agent terminal.Agent, script *automation.Script) {
+	// Start output forwarder
+	go forwardPTYOutput(pts, agent)
+
+	// Monitor process
+	go monitorPTYProcess(pts, agent)
+
+	// Track whether the session needs attention
+	go monitorAttention(pts)
+
+	// Keep the session's resource usage current
+	go monitorUsage(pts)
+
+	if script != nil {
+		go runStartupScript(pts, script)
+	}
+}
+
+// launchHeld starts a session's agent in a holder process, so that it
+// keeps running when the server restarts. The holder keeps the session's
+// record for the server that adopts it.
+func launchHeld(pts *terminal.PTYSession, spec terminal.LaunchSpec, size terminal.Size, group *limits.Group) (terminal.Agent, error) {
+	pts.Mu.RLock()
+	meta, err := json.Marshal(pts.Session)
+	pts.Mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var cgroup string
+	if group != nil {
+		cgroup = group.Path
+	}
+	return holder.Start(holdersDir, holder.Spec{
+		ID:         pts.ID,
+		Command:    spec.Command,
+		Args:       spec.Args,
+		Env:        spec.Env,
+		Dir:        spec.Dir,
+		Rows:       size.Rows,
+		Cols:       size.Cols,
+		Cgroup:     cgroup,
+		Offset:     pts.OutputOffset(),
+		Scrollback: terminal.DefaultScrollbackSize,
+		Meta:       meta,
+	})
+}
+
+// adoptSessions takes over the sessions whose holders outlived an earlier
+// run of the server. Stored records, which are more recent than those the
+// holders keep, are used where they describe the held agent.
+func adoptSessions(dir string, stored map[string]*session.Session) {
+	sockets, err := holder.Discover(dir)
+	if err != nil {
+		log.Printf("Failed to look for held sessions: %v", err)
+		return
+	}
+
+	for _, socket := range sockets {
+		client, err := holder.Dial(socket)
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			log.Printf("Removing socket of a holder that has gone: %s", socket)
+			os.Remove(socket)
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to reach holder %s: %v", socket, err)
+			continue
+		}
+
+		ptySession, err := adoptSession(client, stored[client.Info.Spec.ID])
+		if err != nil {
+			log.Printf("Failed to adopt session from %s: %v", socket, err)
+			client.Detach()
+			continue
+		}
+		log.Printf("Adopted session %s with PID %d", ptySession.ID, client.PID())
+	}
+}
+
+// adoptSession registers a session from its stored record or, failing
+// that, the record its holder kept, and serves its agent. The agent's
+// retained output is replayed, restoring the session's screen and
+// scrollback.
+func adoptSession(client *holder.Client, stored *session.Session) (*terminal.PTYSession, error) {
+	info := client.Info
+	adopted := stored
+	if adopted == nil || adopted.PID != info.PID {
+		adopted = &session.Session{}
+		if err := json.Unmarshal(info.Spec.Meta, adopted); err != nil {
+			return nil, fmt.Errorf("invalid session record: %v", err)
+		}
+		adopted.ID = info.Spec.ID
+	}
+
+	ptySession, err := terminal.NewPTYSession(adopted.ID, adopted)
+	if err != nil {
+		return nil, err
+	}
+	if agent, ok := profileManager.Get(adopted.Profile); ok {
+		ptySession.SetDetection(agent.Ready(), agent.Prompts(), agent.Busy())
+	}
+
+	var sessionLimits session.Limits
+	if adopted.Limits != nil {
+		sessionLimits = *adopted.Limits
+	}
+	ptySession.SetLaunch(terminal.LaunchSpec{
+		Command: info.Spec.Command,
+		Args:    info.Spec.Args,
+		Env:     info.Spec.Env,
+		Dir:     info.Spec.Dir,
+		Limits:  sessionLimits,
+	})
+	ptySession.Adopt(client, info.Started, info.Offset, limits.Adopt(adopted.ID, sessionLimits, adopted.LimitMode))
+
+	if err := registry.Add(ptySession); err != nil {
+		return nil, err
+	}
+	serveSession(ptySession, client, nil)
+	return ptySession, nil
+}
 
+// restoreSessions rebuilds the sessions stored by an earlier run of the
+// server. Those whose holders kept them running are adopted. The rest are
+// reconciled with git and with the processes still running, and those
+// that were live are marked detached, to be relaunched or dismissed.
+func restoreSessions() {
+	stored := make(map[string]*session.Session)
+	if sessionStore != nil {
+		records, err := sessionStore.Load()
+		if err != nil {
+			// Saving now would overwrite whatever the store holds
+			log.Printf("Failed to load stored sessions, which will not be saved: %v", err)
+			sessionStore = nil
+		}
+		for _, record := range records {
+			s, err := record.Restore()
+			if err != nil {
+				log.Printf("Skipping unreadable stored session: %v", err)
+				continue
+			}
+			stored[s.ID] = s
+		}
+	}
+
+	if holdersDir != "" {
+		adoptSessions(holdersDir, stored)
+	}
+
+	for id, s := range stored {
+		if _, adopted := registry.Get(id); adopted {
+			continue
+		}
+		if _, err := restoreSession(s); err != nil {
+			log.Printf("Failed to restore session %s: %v", id, err)
+			continue
+		}
+		log.Printf("Restored session %s (%s)", id, s.Status)
+	}
+	persistSessions()
+}
+
+// restoreSession registers a stored session that no agent runs for any
+// more, at least none this server can reach
+func restoreSession(s *session.Session) (*terminal.PTYSession, error) {
+	reconcileWorktree(s)
+	if !s.Finished() {
+		s.Detached(orphanReason(s))
+	}
+
+	ptySession, err := terminal.NewPTYSession(s.ID, s)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := terminal.LaunchSpec{Dir: s.Path}
+	if len(s.Command) > 0 {
+		spec.Command, spec.Args = s.Command[0], s.Command[1:]
+	}
+	if s.Limits != nil {
+		spec.Limits = *s.Limits
+	}
+	var agentEnv []string
+	if agent, ok := profileManager.Get(s.Profile); ok {
+		ptySession.SetDetection(agent.Ready(), agent.Prompts(), agent.Busy())
+		agentEnv = agent.Environment()
+	}
+	var envOptions session.EnvOptions
+	if s.Environment != nil {
+		envOptions = *s.Environment
+	}
+	// Env files were stored with absolute paths
+	if spec.Env, _, err = environment.Build(os.Environ(), agentEnv, envOptions, ""); err != nil {
+		s.AddEvent("environment", fmt.Sprintf("cannot be rebuilt: %v", err))
+	}
+	ptySession.SetLaunch(spec)
+
+	if err := registry.Add(ptySession); err != nil {
+		return nil, err
+	}
 	return ptySession, nil
 }
 
+// reconcileWorktree checks a stored session's directory against git,
+// noting a directory that has gone and picking up a branch switched since
+func reconcileWorktree(s *session.Session) {
+	path, err := filepath.EvalSymlinks(s.Path)
+	if err != nil {
+		s.AddEvent("worktree", fmt.Sprintf("%s no longer exists", s.Path))
+		return
+	}
+	branches, err := gitWorktreeBranches(path)
+	if err != nil {
+		return // not in a git repository
+	}
+	branch, ok := branches[path]
+	if !ok {
+		return // a directory within a worktree
+	}
+	if branch != s.Branch {
+		s.AddEvent("worktree", fmt.Sprintf("branch changed from %s to %s", s.Branch, branch))
+		s.Branch = branch
+	}
+}
+
+// gitWorktreeBranches returns the worktrees of the repository dir is in,
+// with the branch each has checked out, or "" for a detached HEAD
+func gitWorktreeBranches(dir string) (map[string]string, error) {
+	cmd := exec.Command("git", "worktree", "list", "--porcelain")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make(map[string]string)
+	var current string
+	for _, line := range strings.Split(string(output), "\n") {
+		if path, ok := strings.CutPrefix(line, "worktree "); ok {
+			current = filepath.Clean(path)
+			branches[current] = ""
+		} else if ref, ok := strings.CutPrefix(line, "branch "); ok {
+			branches[current] = strings.TrimPrefix(ref, "refs/heads/")
+		}
+	}
+	return branches, nil
+}
+
+// orphanReason says what became of a live session's agent when the
+// server running it went away
+func orphanReason(s *session.Session) string {
+	if pid, ok := orphanedAgent(s); ok {
+		return fmt.Sprintf("the server restarted; PID %d is still running without a terminal", pid)
+	}
+	return "the server restarted and the agent is gone"
+}
+
+// orphanedAgent returns the PID of a detached session's agent if it is
+// still running. Its start time tells it apart from a process that has
+// reused the PID since.
+func orphanedAgent(s *session.Session) (int, bool) {
+	if s.PID <= 0 || s.Launched == nil {
+		return 0, false
+	}
+	started, err := process.StartedAt(s.PID)
+	if err != nil {
+		return 0, false
+	}
+	if d := started.Sub(*s.Launched); d < -2*time.Second || d > 2*time.Second {
+		return 0, false
+	}
+	return s.PID, true
+}
+
+// killOrphan tears down the agent a detached session left running, if
+// any, so that it is not left behind by a relaunch or dismissal
+func killOrphan(ptySession *terminal.PTYSession) error {
+	ptySession.Mu.RLock()
+	pid, orphaned := 0, false
+	if ptySession.Session.Status == session.StatusDetached {
+		pid, orphaned = orphanedAgent(ptySession.Session)
+	}
+	ptySession.Mu.RUnlock()
+	if !orphaned {
+		return nil
+	}
+	return process.KillTree(pid, process.DefaultGrace)
+}
+
+// persistSessions saves every session to the store
+func persistSessions() {
+	if sessionStore == nil {
+		return
+	}
+
+	sessions := registry.List()
+	records := make([]session.Record, 0, len(sessions))
+	for _, ptySession := range sessions {
+		ptySession.Mu.RLock()
+		record, err := session.NewRecord(ptySession.Session)
+		ptySession.Mu.RUnlock()
+		if err != nil {
+			log.Printf("Failed to record session %s: %v", ptySession.ID, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := sessionStore.Save(records); err != nil {
+		log.Printf("Failed to save sessions: %v", err)
+	}
+}
+
+// watchSessions keeps other subsystems in step with the registry: the
+// store is saved whenever a session comes, changes status or goes, and a
+// removed session's approvals are forgotten
+func watchSessions() {
+	for {
+		events, unsubscribe := registry.Events().Subscribe(256)
+		for event := range events {
+			switch event.Type {
+			case terminal.EventRemoved:
+				approvalManager.Forget(event.SessionID)
+				persistSessions()
+			case terminal.EventCreated, terminal.EventStatus:
+				persistSessions()
+			}
+		}
+		unsubscribe()
+		log.Printf("Session watcher fell behind; catching up")
+		persistSessions()
+	}
+}
+
+// branchInterval is how often sessions' directories are checked for a
+// different branch
+const branchInterval = 5 * time.Second
+
+// monitorBranches keeps each session's branch current, as the agent or
+// the user may check out another in its directory
+func monitorBranches() {
+	ticker := time.NewTicker(branchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, pts := range registry.List() {
+			pts.Mu.RLock()
+			path, branch := pts.Session.Path, pts.Session.Branch
+			pts.Mu.RUnlock()
+			if current := getGitBranch(path); current != "unknown" && current != branch {
+				pts.SetBranch(current)
+			}
+		}
+	}
+}
+
+// monitorStore saves the sessions periodically, so that the store stays
+// close behind them
+func monitorStore() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		persistSessions()
+	}
+}
+
 func getGitBranch(dir string) string {
 	cmd := exec.Command("git", "branch", "--show-current")
 	cmd.Dir = dir
@@ -892,40 +1863,123 @@ func getGitBranch(dir string) string {
 	return strings.TrimSpace(string(output))
 }
 
-func forwardPTYOutput(pts *terminal.PTYSession) {
+func forwardPTYOutput(pts *terminal.PTYSession, agent terminal.Agent) {
+	// Hold back multi-byte characters split across reads so that every
+	// chunk sent to clients ends on a UTF-8 boundary
+	var decoder terminal.OutputDecoder
+
 	buffer := make([]byte, 1024)
 	for {
-		n, err := pts.PTY.Read(buffer)
+		n, err := agent.Read(buffer)
 		if err != nil {
 			break
 		}
 
-		data := buffer[:n]
-
-		// Convert to UTF-8 safe string, replacing invalid bytes
-		output := string(data)
-		if !isValidUTF8(data) {
-			// Convert invalid UTF-8 to safe representation
-			output = strings.ToValidUTF8(string(data), "�")
+		if data := decoder.Feed(buffer[:n]); len(data) > 0 {
+			pts.BroadcastToClients(data)
 		}
+	}
 
-		pts.Mu.RLock()
-		for client := range pts.Clients {
-			if err := client.WriteMessage(websocket.TextMessage, []byte(output)); err != nil {
-				// Client disconnected, will be cleaned up elsewhere
-				continue
+	if data := decoder.Flush(); len(data) > 0 {
+		pts.BroadcastToClients(data)
+	}
+}
+
+// monitorPTYProcess waits for a session's agent to exit and records how it
+// ended, then restarts it if its restart policy says to. Otherwise the
+// session stays listed until it is dismissed.
+func monitorPTYProcess(pts *terminal.PTYSession, agent terminal.Agent) {
+	exited, err := pts.Wait(agent)
+	if errors.Is(err, terminal.ErrDetached) {
+		log.Printf("Session %s left running in its holder", pts.ID)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to tear down session %s: %v", pts.ID, err)
+	}
+	approvalManager.Forget(pts.ID)
+	log.Printf("Session %s is %s", pts.ID, pts.Status())
+
+	if !exited {
+		return
+	}
+	if restart, scheduled := pts.ScheduleRestart(func() { launchSession(pts, nil) }); scheduled {
+		log.Printf("Session %s will restart (restart %d)", pts.ID, restart)
+	}
+}
+
+// attentionInterval is how often sessions are checked for prompts
+const attentionInterval = 250 * time.Millisecond
+
+// monitorAttention keeps the session's activity state and its pending
+// approvals current until the agent's run ends
+func monitorAttention(pts *terminal.PTYSession) {
+	done := pts.Done()
+	ticker := time.NewTicker(attentionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			if pts.UpdateAttention(now) {
+				log.Printf("Session %s is now %s", pts.ID, pts.Attention())
 			}
+			syncApprovals(pts)
 		}
-		pts.Mu.RUnlock()
 	}
 }
 
-func isValidUTF8(data []byte) bool {
-	return utf8.Valid(data)
+// usageInterval is how often sessions' resource usage is sampled
+const usageInterval = 2 * time.Second
+
+// monitorUsage samples the CPU and memory used by a session's processes,
+// and notes any limits they run into, until the agent's run ends
+func monitorUsage(pts *terminal.PTYSession) {
+	done := pts.Done()
+	ticker := time.NewTicker(usageInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			pts.UpdateUsage()
+			for _, breach := range pts.CheckLimits() {
+				log.Printf("Session %s: %s", pts.ID, breach)
+			}
+		}
+	}
 }
 
-func monitorPTYProcess(pts *terminal.PTYSession) {
-	pts.Cmd.Wait()
-	pts.Cleanup()
+// syncApprovals records the permission prompt on a session's screen. While
+// the session is working the screen may be half drawn, so it is left alone.
+func syncApprovals(pts *terminal.PTYSession) {
+	var prompt *approval.Prompt
+	switch pts.Attention() {
+	case attention.Working:
+		return
+	case attention.AwaitingInput:
+		prompt, _ = approval.Parse(strings.Split(pts.ScreenText(), "\n"))
+	}
+
+	pts.Mu.RLock()
+	name := pts.Session.Name
+	pts.Mu.RUnlock()
+
+	if created := approvalManager.Sync(pts.ID, name, prompt); created != nil {
+		log.Printf("Session %s is asking for approval: %s %s", pts.ID, created.Tool, created.Subject)
+	}
 }
 
+// writeSessionInput types data into a session's terminal
+func writeSessionInput(sessionID string, data []byte) error {
+	ptySession, exists := registry.Get(sessionID)
+
+	if !exists {
+		return fmt.Errorf("session %s not found", sessionID)
+	}
+	return ptySession.WriteInput(data)
+}
@@ -13,12 +13,19 @@ class ClaudeManager {
     async init() {
         this.bindEvents();
         await this.loadSessions();
-        this.startSessionPolling();
+        await this.loadApprovals();
+        await this.loadRecordings();
+        this.startSessionEvents();
+        this.startPolling();
     }
 
     bindEvents() {
         document.getElementById('new-session').addEventListener('click', () => this.showSessionCreator());
-        document.getElementById('refresh').addEventListener('click', () => this.loadSessions());
+        document.getElementById('refresh').addEventListener('click', () => {
+            this.loadSessions();
+            this.loadApprovals();
+            this.loadRecordings();
+        });
         
         // Session creator events
         document.getElementById('use-worktree').addEventListener('change', (e) => {
@@ -78,11 +85,19 @@ class ClaudeManager {
             return;
         }
 
-        sessionsList.innerHTML = this.activeSessions.map(session => {
-            let statusClass = 'status-idle';
-            if (session.status === 'active') statusClass = 'status-active';
-            else if (session.status === 'starting') statusClass = 'status-starting';
-            else if (session.status === 'error') statusClass = 'status-error';
+        // Sessions blocked on a prompt go to the top, longest waiting first
+        const rank = { awaiting_input: 0, idle: 1, working: 2 };
+        const sessions = [...this.activeSessions].sort((a, b) =>
+            (rank[a.activity] ?? 3) - (rank[b.activity] ?? 3) ||
+            new Date(a.activity_since) - new Date(b.activity_since));
+
+        sessionsList.innerHTML = sessions.map(session => {
+            const statusClass = `status-${session.status}`;
+            const finished = ['exited', 'failed', 'killed', 'detached'].includes(session.status);
+            let ended = '';
+            if (session.status === 'exited') ended = session.exit_signal || `code ${session.exit_code}`;
+            else if (session.status === 'failed' || session.status === 'detached') ended = session.reason;
+            else if (session.status === 'killed') ended = `by ${session.killed_by}`;
             
             return `
                 <div class="session-item" data-session-id="${session.id}" onclick="app.selectSession('${session.id}')">
@@ -90,16 +105,143 @@ class ClaudeManager {
                     <div class="session-details">
                         <div>${session.path}</div>
                         <div>Branch: ${session.branch}</div>
-                        <span class="session-status ${statusClass}">${session.status}</span>
+                        ${session.processes ? `<div class="session-usage" title="${session.processes} processes">CPU ${session.cpu_percent.toFixed(0)}% · ${this.formatBytes(session.memory_rss)}</div>` : ''}
+                        <span class="session-status ${statusClass}" title="${ended}">${session.status}${session.status === 'exited' ? ` (${ended})` : ''}</span>
+                        ${session.clients ? `<span class="session-status status-clients" title="clients attached to the terminal">${session.clients} attached</span>` : ''}
+                        ${session.restarts ? `<span class="session-status status-restarts" title="restarts since the agent last stayed up">restarted ${session.restarts}×</span>` : ''}
+                        ${session.activity ? `<span class="session-status activity-${session.activity}" title="since ${new Date(session.activity_since).toLocaleTimeString()}">${session.activity.replace('_', ' ')}</span>` : ''}
                     </div>
                     <div class="session-actions">
+                        ${finished ? `
+                        <button class="pause-btn" onclick="app.sessionAction('${session.id}', 'relaunch', event)">Relaunch</button>
+                        <button class="kill-btn" onclick="app.sessionAction('${session.id}', 'dismiss', event)">Dismiss</button>
+                        ` : `
+                        ${session.status === 'paused'
+                            ? `<button class="pause-btn" onclick="app.sessionAction('${session.id}', 'resume', event)">Resume</button>`
+                            : `<button class="pause-btn" onclick="app.sessionAction('${session.id}', 'pause', event)">Pause</button>`}
                         <button class="kill-btn" onclick="app.killSession('${session.id}', event)">Kill</button>
+                        `}
                     </div>
                 </div>
             `;
         }).join('');
     }
 
+    formatBytes(bytes) {
+        const units = ['B', 'KB', 'MB', 'GB'];
+        let i = 0;
+        while (bytes >= 1024 && i < units.length - 1) {
+            bytes /= 1024;
+            i++;
+        }
+        return `${bytes.toFixed(i === 0 ? 0 : 1)} ${units[i]}`;
+    }
+
+    async loadApprovals() {
+        try {
+            const response = await fetch('/api/approvals');
+            const approvals = await response.json();
+            this.renderApprovals(approvals || []);
+        } catch (error) {
+            console.error('Failed to load approvals:', error);
+        }
+    }
+
+    renderApprovals(approvals) {
+        const approvalsList = document.getElementById('approvals-list');
+
+        if (approvals.length === 0) {
+            approvalsList.innerHTML = '<div class="no-sessions">Nothing waiting for approval</div>';
+            return;
+        }
+
+        const escape = text => String(text).replace(/[&<>"']/g, c => `&#${c.charCodeAt(0)};`);
+        approvalsList.innerHTML = approvals.map(approval => `
+            <div class="session-item approval-item">
+                <div class="session-name">${escape(approval.sessionName)}: ${escape(approval.tool)}</div>
+                <div class="session-details">
+                    <div class="approval-subject">${escape(approval.subject || '')}</div>
+                    <div>${escape(approval.question)}</div>
+                </div>
+                <div class="session-actions">
+                    ${approval.options.map(option => `
+                        <button class="approval-btn" title="${escape(option.label)}"
+                            onclick="app.resolveApproval('${approval.id}', ${option.number})">${option.number}. ${escape(option.label.replace(/\s*\(esc\)$/, '').slice(0, 40))}</button>
+                    `).join('')}
+                </div>
+            </div>
+        `).join('');
+    }
+
+    async resolveApproval(id, option) {
+        try {
+            const response = await fetch(`/api/approvals/${id}`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ option: option })
+            });
+            if (!response.ok) {
+                alert('Failed to answer: ' + await response.text());
+            }
+        } catch (error) {
+            console.error('Failed to resolve approval:', error);
+        }
+        await this.loadApprovals();
+    }
+
+    async loadRecordings() {
+        try {
+            const response = await fetch('/api/recordings');
+            const recordings = await response.json();
+            this.renderRecordings(recordings || []);
+        } catch (error) {
+            console.error('Failed to load recordings:', error);
+        }
+    }
+
+    renderRecordings(recordings) {
+        const recordingsList = document.getElementById('recordings-list');
+
+        if (recordings.length === 0) {
+            recordingsList.innerHTML = '<div class="no-sessions">No recordings</div>';
+            return;
+        }
+
+        recordingsList.innerHTML = recordings.map(recording => {
+            const minutes = Math.floor(recording.duration / 60);
+            const seconds = String(Math.floor(recording.duration % 60)).padStart(2, '0');
+            return `
+                <div class="session-item" onclick="app.playRecording('${recording.name}', '${recording.title || recording.name}')">
+                    <div class="session-name">${recording.title || recording.name}</div>
+                    <div class="session-details">
+                        <div>${new Date(recording.started).toLocaleString()}</div>
+                        <div>Duration: ${minutes}:${seconds}</div>
+                    </div>
+                </div>
+            `;
+        }).join('');
+    }
+
+    playRecording(name, title) {
+        if (this.currentSession) {
+            this.disconnectFromCurrentSession();
+            this.currentSession = null;
+        }
+
+        document.getElementById('welcome-screen').style.display = 'none';
+        document.getElementById('terminal-area').style.display = 'flex';
+        document.getElementById('terminal-title').textContent = `▶️ ${title}`;
+
+        const terminalDiv = document.getElementById('terminal');
+        terminalDiv.innerHTML = `
+            <iframe 
+                src="/playback/${encodeURIComponent(name)}" 
+                style="width: 100%; height: 100%; border: none; background: #1e1e1e;"
+                frameborder="0">
+            </iframe>
+        `;
+    }
+
     selectSession(sessionId) {
         console.log('Selecting session:', sessionId);
         
@@ -134,6 +276,7 @@ class ClaudeManager {
         
         // Update header
         document.getElementById('terminal-title').textContent = `${session.name} - ${session.path}`;
+        this.updateRecordButton(!!session.recording);
         
         // Use our proven working approach - iframe with the working terminal page
         const terminalDiv = document.getElementById('terminal');
@@ -217,6 +360,36 @@ class ClaudeManager {
         }
     }
 
+    async toggleRecording() {
+        if (!this.currentSession) return;
+
+        const session = this.activeSessions.find(s => s.id === this.currentSession);
+        const enabled = !(session && session.recording);
+
+        try {
+            const response = await fetch(`/api/sessions/${this.currentSession}/recording`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ enabled })
+            });
+
+            if (response.ok) {
+                const status = await response.json();
+                if (session) session.recording = status.recording || '';
+                this.updateRecordButton(status.active);
+            } else {
+                alert('Failed to change recording: ' + await response.text());
+            }
+        } catch (error) {
+            console.error('Failed to change recording:', error);
+        }
+    }
+
+    updateRecordButton(recording) {
+        const button = document.getElementById('record-toggle');
+        button.textContent = recording ? '⏹️ Stop Recording' : '⏺️ Record';
+    }
+
     closeTerminal() {
         if (this.currentWebSocket) {
             this.currentWebSocket.close();
@@ -267,6 +440,31 @@ class ClaudeManager {
         document.getElementById('session-creator').style.display = 'flex';
         document.getElementById('session-name').focus();
         this.loadDirectories();
+        this.loadProfiles();
+    }
+
+    async loadProfiles() {
+        try {
+            const response = await fetch('/api/profiles');
+            const profiles = await response.json();
+            this.profiles = profiles;
+            const select = document.getElementById('session-profile');
+            select.innerHTML = '<option value="">Default</option>' + profiles.map(profile => `
+                <option value="${profile.name}" ${profile.available ? '' : 'disabled'}>
+                    ${profile.name}${profile.description ? ` – ${profile.description}` : ''}${profile.available ? '' : ' (not installed)'}
+                </option>
+            `).join('');
+        } catch (error) {
+            console.error('Failed to load profiles:', error);
+        }
+    }
+
+    // Claude's launch options only apply to profiles that run claude
+    updateClaudeOptions() {
+        const name = document.getElementById('session-profile').value;
+        const profile = (this.profiles || []).find(p => p.name === name);
+        const isClaude = !profile || profile.command.split('/').pop() === 'claude';
+        document.getElementById('claude-options').style.display = isClaude ? '' : 'none';
     }
 
     hideSessionCreator() {
@@ -275,6 +473,12 @@ class ClaudeManager {
         document.getElementById('branch-name').value = '';
         document.getElementById('base-branch').value = '';
         document.getElementById('use-worktree').checked = true;
+        document.getElementById('record-session').checked = false;
+        document.getElementById('session-profile').value = '';
+        document.getElementById('claude-prompt').value = '';
+        document.getElementById('claude-model').value = '';
+        document.getElementById('claude-permission-mode').value = '';
+        this.updateClaudeOptions();
         this.selectedRepoPath = '';
         document.getElementById('selected-repo-path').textContent = 'None selected';
     }
@@ -366,6 +570,14 @@ class ClaudeManager {
         const branchName = document.getElementById('branch-name').value.trim();
         const baseBranch = document.getElementById('base-branch').value.trim() || 'main';
         const useWorktree = document.getElementById('use-worktree').checked;
+        const record = document.getElementById('record-session').checked;
+        const profile = document.getElementById('session-profile').value;
+        const restart = { policy: document.getElementById('restart-policy').value };
+        const claudeOptions = document.getElementById('claude-options').style.display === 'none' ? {} : {
+            prompt: document.getElementById('claude-prompt').value.trim(),
+            model: document.getElementById('claude-model').value.trim(),
+            permissionMode: document.getElementById('claude-permission-mode').value
+        };
 
         if (!name) {
             alert('Please enter a session name');
@@ -386,7 +598,11 @@ class ClaudeManager {
                     repoPath,
                     branchName: branchName || `feature/${name}`,
                     baseBranch,
-                    useWorktree
+                    useWorktree,
+                    record,
+                    profile,
+                    restart,
+                    ...claudeOptions
                 })
             });
 
@@ -405,6 +621,22 @@ class ClaudeManager {
         }
     }
 
+    async sessionAction(sessionId, action, event) {
+        event.stopPropagation();
+
+        try {
+            const response = await fetch(`/api/sessions/${sessionId}/${action}`, { method: 'POST' });
+            if (!response.ok) {
+                alert(`Failed to ${action} session: ` + await response.text());
+            } else if (action === 'dismiss' && this.currentSession === sessionId) {
+                this.closeTerminal();
+            }
+        } catch (error) {
+            console.error(`Failed to ${action} session:`, error);
+        }
+        await this.loadSessions();
+    }
+
     async killSession(sessionId, event) {
         event.stopPropagation();
         
@@ -421,10 +653,6 @@ class ClaudeManager {
 
             if (response.ok) {
                 await this.loadSessions();
-                
-                if (this.currentSession === sessionId) {
-                    this.closeTerminal();
-                }
             } else {
                 alert('Failed to kill session');
             }
@@ -434,10 +662,39 @@ class ClaudeManager {
         }
     }
 
-    startSessionPolling() {
-        setInterval(() => {
-            this.loadSessions();
-        }, 5000);
+    // Session changes arrive as events. EventSource reconnects by itself,
+    // resuming from the last event it saw.
+    startSessionEvents() {
+        const events = new EventSource('/api/events');
+        events.addEventListener('sessions', (e) => {
+            this.activeSessions = JSON.parse(e.data) || [];
+            this.renderSessions();
+        });
+        for (const type of ['created', 'status', 'client_attached', 'client_left', 'activity', 'branch', 'exited', 'limit_breach']) {
+            events.addEventListener(type, (e) => this.applySessionEvent(JSON.parse(e.data)));
+        }
+        events.addEventListener('removed', (e) => {
+            const event = JSON.parse(e.data);
+            this.activeSessions = this.activeSessions.filter(s => s.id !== event.session_id);
+            this.renderSessions();
+        });
+    }
+
+    applySessionEvent(event) {
+        const index = this.activeSessions.findIndex(s => s.id === event.session_id);
+        if (index >= 0) {
+            this.activeSessions[index] = event.session;
+        } else {
+            this.activeSessions.push(event.session);
+        }
+        this.renderSessions();
+    }
+
+    // Approvals, and the resource usage shown for sessions, are still
+    // fetched periodically
+    startPolling() {
+        setInterval(() => this.loadApprovals(), 5000);
+        setInterval(() => this.loadSessions(), 30000);
     }
 }
 